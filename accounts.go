@@ -0,0 +1,429 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/database"
+)
+
+const (
+	defaultAccountDeletionGrace = 30 * 24 * time.Hour
+	dataExportDuration          = 7 * 24 * time.Hour
+	dataExportTimeout           = 5 * time.Minute
+	maintenanceInterval         = time.Hour
+	// dataExportRetryDelay is how long after a failed export another can
+	// be started.
+	dataExportRetryDelay = 15 * time.Minute
+
+	dataExportPending = "pending"
+	dataExportReady   = "ready"
+	dataExportFailed  = "failed"
+)
+
+type DataExport struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Status      string     `json:"status"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+func (cfg *apiConfig) dataExportFromDB(dbExport database.DataExport) DataExport {
+	export := DataExport{
+		ID:        dbExport.ID,
+		CreatedAt: dbExport.CreatedAt,
+		Status:    dbExport.Status,
+	}
+	if dbExport.Status == dataExportReady {
+		export.ExpiresAt = &dbExport.ExpiresAt.Time
+		export.DownloadURL = cfg.appBaseURL + "/api/users/me/export/" + dbExport.ID.String()
+	}
+	return export
+}
+
+// handlerUserDelete closes the caller's account. With a grace period the
+// account is only marked deleted and can be restored by logging in again
+// before it runs out; otherwise it's removed straight away.
+func (cfg *apiConfig) handlerUserDelete(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Password string `json:"password"`
+	}
+	type response struct {
+		DeletedAt  time.Time `json:"deleted_at"`
+		PurgeAfter time.Time `json:"purge_after"`
+	}
+
+	userID := principalFrom(r.Context()).UserID
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find user", err)
+		return
+	}
+	if !cfg.checkCurrentPassword(w, r, user, params.Password) {
+		return
+	}
+
+	if cfg.accountDeletionGrace == 0 {
+		// Only accounts marked deleted are purged.
+		if _, err := cfg.db.SoftDeleteUser(r.Context(), userID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't delete account", err)
+			return
+		}
+		if err := cfg.purgeUser(r.Context(), userID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't delete account", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete account", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	user, err = qtx.SoftDeleteUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete account", err)
+		return
+	}
+	if err := qtx.RevokeUserSessions(r.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete account", err)
+		return
+	}
+	if err := qtx.RevokeUserAPIKeys(r.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete account", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete account", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, response{
+		DeletedAt:  user.DeletedAt.Time,
+		PurgeAfter: user.DeletedAt.Time.Add(cfg.accountDeletionGrace),
+	})
+}
+
+// purgeUser deletes an account for good if it's still marked deleted and
+// the grace period has run out. Everything the user owns in the database
+// goes with it through ON DELETE CASCADE, except chirps others replied to,
+// which are left as tombstones with no author. Their files are removed
+// afterwards.
+func (cfg *apiConfig) purgeUser(ctx context.Context, userID uuid.UUID) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// The user may have logged in and restored the account since it was
+	// listed. Holding the row keeps them from doing so until we're done.
+	_, err = qtx.LockUserForPurge(ctx, database.LockUserForPurgeParams{
+		ID:           userID,
+		GraceSeconds: cfg.accountDeletionGrace.Seconds(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	mediaKeys, err := qtx.ListUserMediaKeys(ctx, userID)
+	if err != nil {
+		return err
+	}
+	exportKeys, err := qtx.ListUserDataExportKeys(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	author := uuid.NullUUID{UUID: userID, Valid: true}
+	// Locked so nobody replies to a chirp after it's been found to have no
+	// replies.
+	if err := qtx.LockUserChirps(ctx, author); err != nil {
+		return err
+	}
+	tombstoned, err := qtx.TombstoneUserChirps(ctx, author)
+	if err != nil {
+		return err
+	}
+	for _, chirpID := range tombstoned {
+		if err := qtx.DeleteChirpEntities(ctx, chirpID); err != nil {
+			return err
+		}
+		if err := qtx.DeleteChirpRevisions(ctx, chirpID); err != nil {
+			return err
+		}
+	}
+	// The cascade doesn't touch the counts kept on other chirps.
+	if err := qtx.DecrementUserReplyParents(ctx, author); err != nil {
+		return err
+	}
+	if err := qtx.DecrementUserLikedChirps(ctx, userID); err != nil {
+		return err
+	}
+	if err := qtx.DeleteUser(ctx, userID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, keys := range mediaKeys {
+		if err := cfg.storage.Delete(ctx, keys.StorageKey); err != nil {
+			log.Printf("Error deleting media %s: %s", keys.StorageKey, err)
+		}
+		if err := cfg.storage.Delete(ctx, keys.ThumbnailKey); err != nil {
+			log.Printf("Error deleting media %s: %s", keys.ThumbnailKey, err)
+		}
+	}
+	for _, key := range exportKeys {
+		if err := cfg.exports.Delete(ctx, key.String); err != nil {
+			log.Printf("Error deleting export %s: %s", key.String, err)
+		}
+	}
+	return nil
+}
+
+// handlerUserExport reports on the caller's latest data export, starting a
+// new one if there isn't one in progress or ready to download.
+func (cfg *apiConfig) handlerUserExport(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r.Context()).UserID
+
+	latest, err := cfg.db.GetLatestDataExport(r.Context(), userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve export", err)
+		return
+	}
+	if err == nil {
+		switch {
+		case latest.Status == dataExportPending:
+			respondWithJSON(w, http.StatusAccepted, cfg.dataExportFromDB(latest))
+			return
+		case latest.Status == dataExportReady && time.Now().UTC().Before(latest.ExpiresAt.Time):
+			respondWithJSON(w, http.StatusOK, cfg.dataExportFromDB(latest))
+			return
+		case latest.Status == dataExportFailed:
+			retryAt := latest.UpdatedAt.Add(dataExportRetryDelay)
+			if wait := time.Until(retryAt); wait > 0 {
+				w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+				respondWithError(w, http.StatusTooManyRequests, "Export failed recently, try again later", nil)
+				return
+			}
+		}
+	}
+
+	// Only one pending export per user can be inserted, so of two requests
+	// racing here only one starts building.
+	export, err := cfg.db.CreateDataExport(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		export, err = cfg.db.GetLatestDataExport(r.Context(), userID)
+		if err == nil {
+			respondWithJSON(w, http.StatusAccepted, cfg.dataExportFromDB(export))
+			return
+		}
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start export", err)
+		return
+	}
+	go cfg.buildDataExport(export)
+
+	respondWithJSON(w, http.StatusAccepted, cfg.dataExportFromDB(export))
+}
+
+func (cfg *apiConfig) handlerUserExportDownload(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r.Context()).UserID
+
+	exportID, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid export ID", err)
+		return
+	}
+
+	export, err := cfg.db.GetDataExport(r.Context(), database.GetDataExportParams{
+		ID:     exportID,
+		UserID: userID,
+	})
+	if err != nil || export.Status != dataExportReady || !time.Now().UTC().Before(export.ExpiresAt.Time) {
+		respondWithError(w, http.StatusNotFound, "Export not found", err)
+		return
+	}
+
+	f, err := cfg.exports.Open(r.Context(), export.StorageKey.String)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't open export", err)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="chirpy-export-%s.zip"`, export.CreatedAt.Format("2006-01-02")))
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, f)
+}
+
+// buildDataExport runs in the background, so it has its own context rather
+// than the request's.
+func (cfg *apiConfig) buildDataExport(export database.DataExport) {
+	ctx, cancel := context.WithTimeout(context.Background(), dataExportTimeout)
+	defer cancel()
+
+	key := export.ID.String() + ".zip"
+	err := cfg.writeDataExport(ctx, export.UserID, key)
+	if err == nil {
+		err = cfg.db.CompleteDataExport(ctx, database.CompleteDataExportParams{
+			ID:         export.ID,
+			StorageKey: sql.NullString{String: key, Valid: true},
+			ExpiresAt:  sql.NullTime{Time: time.Now().UTC().Add(dataExportDuration), Valid: true},
+		})
+	}
+	if err != nil {
+		log.Printf("Error building data export %s: %s", export.ID, err)
+		cfg.exports.Delete(ctx, key)
+		if err := cfg.db.FailDataExport(ctx, export.ID); err != nil {
+			log.Printf("Error marking data export %s failed: %s", export.ID, err)
+		}
+	}
+}
+
+// writeDataExport stores a zip of the user's profile, chirps and sessions,
+// each as a JSON file in the same shape the API returns them.
+func (cfg *apiConfig) writeDataExport(ctx context.Context, userID uuid.UUID, key string) error {
+	dbUser, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	profile, err := cfg.userFromDB(ctx, dbUser)
+	if err != nil {
+		return err
+	}
+
+	dbChirps, err := cfg.db.ListUserChirps(ctx, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		return err
+	}
+	chirps := chirpsFromDB(dbChirps)
+
+	dbSessions, err := cfg.db.ListActiveSessions(ctx, database.ListActiveSessionsParams{
+		UserID: userID,
+		Now:    time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	sessions := make([]Session, 0, len(dbSessions))
+	for _, dbSession := range dbSessions {
+		sessions = append(sessions, Session{
+			ID:        dbSession.SessionID,
+			CreatedAt: dbSession.CreatedAt,
+			ExpiresAt: dbSession.ExpiresAt,
+			UserAgent: dbSession.UserAgent,
+			IpAddress: dbSession.IpAddress,
+		})
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", profile},
+		{"chirps.json", chirps},
+		{"sessions.json", sessions},
+	}
+	for _, file := range files {
+		fw, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	return cfg.exports.Put(ctx, key, &buf)
+}
+
+// runMaintenance purges accounts whose grace period has run out, removes
+// expired exports and authorization codes, and expires lapsed
+// subscriptions, once at startup and then every interval.
+func (cfg *apiConfig) runMaintenance(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		cfg.purgeDeletedUsers(ctx)
+		cfg.removeExpiredExports(ctx)
+		cfg.removeExpiredOAuthCodes(ctx)
+		cfg.expireSubscriptions(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) purgeDeletedUsers(ctx context.Context) {
+	userIDs, err := cfg.db.ListUsersPendingDeletion(ctx, cfg.accountDeletionGrace.Seconds())
+	if err != nil {
+		log.Printf("Error listing deleted accounts: %s", err)
+		return
+	}
+	for _, userID := range userIDs {
+		if err := cfg.purgeUser(ctx, userID); err != nil {
+			log.Printf("Error purging account %s: %s", userID, err)
+		}
+	}
+}
+
+func (cfg *apiConfig) removeExpiredExports(ctx context.Context) {
+	expired, err := cfg.db.ListExpiredDataExports(ctx, time.Now().UTC())
+	if err != nil {
+		log.Printf("Error listing expired exports: %s", err)
+		return
+	}
+	for _, export := range expired {
+		if err := cfg.exports.Delete(ctx, export.StorageKey.String); err != nil {
+			log.Printf("Error deleting export %s: %s", export.ID, err)
+			continue
+		}
+		if err := cfg.db.DeleteDataExport(ctx, export.ID); err != nil {
+			log.Printf("Error deleting export %s: %s", export.ID, err)
+		}
+	}
+}
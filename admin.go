@@ -0,0 +1,28 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/mrcordova/chirpy/internal/auth"
+)
+
+// requireAdmin checks the request carries the admin API key and writes an
+// error response if it doesn't.
+func (cfg *apiConfig) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if cfg.adminApiKey == "" {
+		respondWithError(w, http.StatusForbidden, "Admin API is disabled", nil)
+		return false
+	}
+	apiKey, err := auth.GetApiKey(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find api key", err)
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.adminApiKey)) != 1 {
+		respondWithError(w, http.StatusUnauthorized, "incorrect api key", errors.New("incorrect admin api key"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/auth"
+	"github.com/mrcordova/chirpy/internal/database"
+)
+
+const (
+	defaultAPIKeyDuration = 90 * 24 * time.Hour
+	maxAPIKeyDuration     = 365 * 24 * time.Hour
+	maxAPIKeyLabelLength  = 50
+	// apiKeyPrefixLength is how much of a key is kept in the clear so
+	// users can tell their keys apart.
+	apiKeyPrefixLength = len(auth.APIKeyPrefix) + 6
+)
+
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Label      string     `json:"label"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	// Key is only ever sent in the response that creates it.
+	Key string `json:"key,omitempty"`
+}
+
+func apiKeyFromDB(k database.ApiKey) APIKey {
+	key := APIKey{
+		ID:        k.ID,
+		CreatedAt: k.CreatedAt,
+		Label:     k.Label,
+		Prefix:    k.Prefix,
+		Scopes:    k.Scopes,
+		ExpiresAt: k.ExpiresAt,
+	}
+	if k.LastUsedAt.Valid {
+		key.LastUsedAt = &k.LastUsedAt.Time
+	}
+	return key
+}
+
+func (cfg *apiConfig) handlerAPIKeysCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Label     string     `json:"label"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	userID := principalFrom(r.Context()).UserID
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	if params.Label == "" || utf8.RuneCountInString(params.Label) > maxAPIKeyLabelLength {
+		respondWithError(w, http.StatusBadRequest, "Label must be between 1 and 50 characters", nil)
+		return
+	}
+	scopes, err := auth.ValidateScopes(params.Scopes, auth.DelegableScopes)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if len(scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "An API key needs at least one scope", nil)
+		return
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(defaultAPIKeyDuration)
+	if params.ExpiresAt != nil {
+		expiresAt = params.ExpiresAt.UTC()
+		if !expiresAt.After(now) || expiresAt.Sub(now) > maxAPIKeyDuration {
+			respondWithError(w, http.StatusBadRequest, "expires_at must be in the next 365 days", nil)
+			return
+		}
+	}
+
+	key, err := auth.MakeAPIKey()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create API key", err)
+		return
+	}
+	dbKey, err := cfg.db.CreateAPIKey(r.Context(), database.CreateAPIKeyParams{
+		UserID:    userID,
+		Label:     params.Label,
+		KeyHash:   auth.HashToken(key),
+		Prefix:    key[:apiKeyPrefixLength],
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create API key", err)
+		return
+	}
+
+	created := apiKeyFromDB(dbKey)
+	created.Key = key
+	respondWithJSON(w, http.StatusCreated, created)
+}
+
+func (cfg *apiConfig) handlerAPIKeysList(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r.Context()).UserID
+
+	dbKeys, err := cfg.db.ListAPIKeys(r.Context(), database.ListAPIKeysParams{
+		UserID: userID,
+		Now:    time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve API keys", err)
+		return
+	}
+
+	keys := make([]APIKey, 0, len(dbKeys))
+	for _, dbKey := range dbKeys {
+		keys = append(keys, apiKeyFromDB(dbKey))
+	}
+	respondWithJSON(w, http.StatusOK, keys)
+}
+
+func (cfg *apiConfig) handlerAPIKeyDelete(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r.Context()).UserID
+
+	keyID, err := uuid.Parse(r.PathValue("keyID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid API key ID", err)
+		return
+	}
+
+	rows, err := cfg.db.RevokeAPIKey(r.Context(), database.RevokeAPIKeyParams{
+		ID:     keyID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke API key", err)
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, http.StatusNotFound, "API key not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
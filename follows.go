@@ -0,0 +1,238 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/database"
+)
+
+type Follow struct {
+	UserID     uuid.UUID `json:"user_id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type followPage struct {
+	Users      []Follow `json:"users"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+	PrevCursor string   `json:"prev_cursor,omitempty"`
+}
+
+func (cfg *apiConfig) handlerFollow(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r.Context()).UserID
+	if !cfg.requireVerified(w, r, userID, actionFollow) {
+		return
+	}
+
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+	if followeeID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can't follow yourself", nil)
+		return
+	}
+
+	_, err = cfg.db.GetUserByID(r.Context(), followeeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	err = cfg.db.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't follow user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnfollow(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r.Context()).UserID
+
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	removed, err := cfg.db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unfollow user", err)
+		return
+	}
+	if removed == 0 {
+		respondWithError(w, http.StatusNotFound, "Not following user", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerFollowers(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+	limit, cursor, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	cursorCreatedAt, cursorID := cursorArgs(cursor)
+
+	follows := []Follow{}
+	if cursor != nil && cursor.Direction == cursorPrev {
+		rows, err := cfg.db.ListFollowersAfter(r.Context(), database.ListFollowersAfterParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       int32(limit + 1),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve followers", err)
+			return
+		}
+		for _, row := range rows {
+			follows = append(follows, Follow{UserID: row.UserID, FollowedAt: row.CreatedAt})
+		}
+	} else {
+		rows, err := cfg.db.ListFollowersBefore(r.Context(), database.ListFollowersBeforeParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       int32(limit + 1),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve followers", err)
+			return
+		}
+		for _, row := range rows {
+			follows = append(follows, Follow{UserID: row.UserID, FollowedAt: row.CreatedAt})
+		}
+	}
+
+	respondWithFollowPage(w, follows, limit, cursor)
+}
+
+func (cfg *apiConfig) handlerFollowing(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+	limit, cursor, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	cursorCreatedAt, cursorID := cursorArgs(cursor)
+
+	follows := []Follow{}
+	if cursor != nil && cursor.Direction == cursorPrev {
+		rows, err := cfg.db.ListFollowingAfter(r.Context(), database.ListFollowingAfterParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       int32(limit + 1),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve following", err)
+			return
+		}
+		for _, row := range rows {
+			follows = append(follows, Follow{UserID: row.UserID, FollowedAt: row.CreatedAt})
+		}
+	} else {
+		rows, err := cfg.db.ListFollowingBefore(r.Context(), database.ListFollowingBeforeParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       int32(limit + 1),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve following", err)
+			return
+		}
+		for _, row := range rows {
+			follows = append(follows, Follow{UserID: row.UserID, FollowedAt: row.CreatedAt})
+		}
+	}
+
+	respondWithFollowPage(w, follows, limit, cursor)
+}
+
+func respondWithFollowPage(w http.ResponseWriter, follows []Follow, limit int, cursor *pageCursor) {
+	page, next, prev := paginate(follows, limit, cursor, func(f Follow) (time.Time, uuid.UUID) {
+		return f.FollowedAt, f.UserID
+	})
+	respondWithJSON(w, http.StatusOK, followPage{
+		Users:      page,
+		NextCursor: next,
+		PrevCursor: prev,
+	})
+}
+
+func (cfg *apiConfig) handlerTimeline(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r.Context()).UserID
+
+	limit, cursor, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	cursorCreatedAt, cursorID := cursorArgs(cursor)
+
+	var dbChirps []database.Chirp
+	if cursor != nil && cursor.Direction == cursorPrev {
+		dbChirps, err = cfg.db.ListTimelineAfter(r.Context(), database.ListTimelineAfterParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       int32(limit + 1),
+		})
+	} else {
+		dbChirps, err = cfg.db.ListTimelineBefore(r.Context(), database.ListTimelineBeforeParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       int32(limit + 1),
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve timeline", err)
+		return
+	}
+
+	page, next, prev := paginate(dbChirps, limit, cursor, func(c database.Chirp) (time.Time, uuid.UUID) {
+		return c.CreatedAt, c.ID
+	})
+
+	chirps := chirpsFromDB(page)
+	if err := cfg.decorateChirps(r, chirpRefs(chirps)); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve timeline", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirpPage{
+		Chirps:     chirps,
+		NextCursor: next,
+		PrevCursor: prev,
+	})
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/database"
+	"github.com/mrcordova/chirpy/internal/entities"
+)
+
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 7 * 24 * time.Hour
+	defaultTrendingLimit  = 10
+)
+
+type HashtagEntity struct {
+	Tag   string `json:"tag"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+type MentionEntity struct {
+	Handle string `json:"handle"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+}
+
+type ChirpEntities struct {
+	Hashtags []HashtagEntity `json:"hashtags"`
+	Mentions []MentionEntity `json:"mentions"`
+}
+
+// chirpEntities parses the hashtags and mentions out of a stored body. The
+// offsets are the same ones saved when the chirp was created.
+func chirpEntities(body string) ChirpEntities {
+	result := ChirpEntities{Hashtags: []HashtagEntity{}, Mentions: []MentionEntity{}}
+	for _, entity := range entities.Parse(body) {
+		switch entity.Type {
+		case entities.TypeHashtag:
+			result.Hashtags = append(result.Hashtags, HashtagEntity{
+				Tag:   entities.NormalizeTag(entity.Text),
+				Start: entity.Start,
+				End:   entity.End,
+			})
+		case entities.TypeMention:
+			result.Mentions = append(result.Mentions, MentionEntity{
+				Handle: entity.Text,
+				Start:  entity.Start,
+				End:    entity.End,
+			})
+		}
+	}
+	return result
+}
+
+// saveChirpEntities records a new chirp's hashtags and mentions so they can
+// be looked up without scanning chirp bodies. Mentions are linked to the
+// user who has that handle, if anyone does.
+func saveChirpEntities(ctx context.Context, db *database.Queries, chirp database.Chirp) error {
+	parsed := chirpEntities(chirp.Body)
+	for _, hashtag := range parsed.Hashtags {
+		err := db.CreateChirpHashtag(ctx, database.CreateChirpHashtagParams{
+			ChirpID:     chirp.ID,
+			Tag:         hashtag.Tag,
+			StartOffset: int32(hashtag.Start),
+			EndOffset:   int32(hashtag.End),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, mention := range parsed.Mentions {
+		mentioned := uuid.NullUUID{}
+		user, err := db.GetUserByHandle(ctx, mention.Handle)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err == nil {
+			mentioned = uuid.NullUUID{UUID: user.ID, Valid: true}
+		}
+		err = db.CreateChirpMention(ctx, database.CreateChirpMentionParams{
+			ChirpID:     chirp.ID,
+			Handle:      mention.Handle,
+			UserID:      mentioned,
+			StartOffset: int32(mention.Start),
+			EndOffset:   int32(mention.End),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	tag := entities.NormalizeTag(r.PathValue("tag"))
+	if tag == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid hashtag", nil)
+		return
+	}
+	limit, cursor, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	cursorCreatedAt, cursorID := cursorArgs(cursor)
+
+	var dbChirps []database.Chirp
+	if cursor != nil && cursor.Direction == cursorPrev {
+		dbChirps, err = cfg.db.ListHashtagChirpsAfter(r.Context(), database.ListHashtagChirpsAfterParams{
+			Tag:             tag,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       int32(limit + 1),
+		})
+	} else {
+		dbChirps, err = cfg.db.ListHashtagChirpsBefore(r.Context(), database.ListHashtagChirpsBeforeParams{
+			Tag:             tag,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       int32(limit + 1),
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
+		return
+	}
+
+	page, next, prev := paginate(dbChirps, limit, cursor, func(c database.Chirp) (time.Time, uuid.UUID) {
+		return c.CreatedAt, c.ID
+	})
+
+	chirps := chirpsFromDB(page)
+	if err := cfg.decorateChirps(r, chirpRefs(chirps)); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirpPage{
+		Chirps:     chirps,
+		NextCursor: next,
+		PrevCursor: prev,
+	})
+}
+
+func (cfg *apiConfig) handlerTrendingHashtags(w http.ResponseWriter, r *http.Request) {
+	type trendingHashtag struct {
+		Tag        string `json:"tag"`
+		ChirpCount int64  `json:"chirp_count"`
+	}
+
+	window := defaultTrendingWindow
+	if s := r.URL.Query().Get("window"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 || d > maxTrendingWindow {
+			respondWithError(w, http.StatusBadRequest, "window must be a duration up to 168h", err)
+			return
+		}
+		window = d
+	}
+
+	limit := defaultTrendingLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		l, err := parsePageLimit(s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		limit = l
+	}
+
+	rows, err := cfg.db.ListTrendingHashtags(r.Context(), database.ListTrendingHashtagsParams{
+		WindowSeconds: window.Seconds(),
+		PageLimit:     int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve trending hashtags", err)
+		return
+	}
+
+	trending := make([]trendingHashtag, 0, len(rows))
+	for _, row := range rows {
+		trending = append(trending, trendingHashtag{Tag: row.Tag, ChirpCount: row.ChirpCount})
+	}
+
+	respondWithJSON(w, http.StatusOK, trending)
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -19,8 +20,29 @@ type TokenType string
 const (
 	// TokenTypeAccess -
 	TokenTypeAccess TokenType = "chirpy-access"
+	// TokenTypeChallenge -
+	TokenTypeChallenge TokenType = "chirpy-2fa-challenge"
+	// TokenTypeLoginState -
+	TokenTypeLoginState TokenType = "chirpy-oidc-state"
 )
 
+// Access tokens follow RFC 9068. Challenge and login state tokens are
+// signed with the same published keys, so anyone verifying an access token
+// must check its typ header is AccessTokenHeaderType and its aud claim is
+// AccessTokenAudience, not only the signature and expiry.
+const (
+	AccessTokenHeaderType = "at+jwt"
+	AccessTokenAudience   = "chirpy"
+)
+
+// headerType is the typ header of tokens of type t.
+func (t TokenType) headerType() string {
+	if t == TokenTypeAccess {
+		return AccessTokenHeaderType
+	}
+	return string(t) + "+jwt"
+}
+
 // ErrNoAuthHeaderIncluded -
 var ErrNoAuthHeaderIncluded = errors.New("no auth header included in request")
 
@@ -38,54 +60,203 @@ func CheckPasswordHash(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
+// accessClaims are the claims in an access token. SessionID ties the token
+// to the login session, and so the refresh token family, that issued it.
+type accessClaims struct {
+	jwt.RegisteredClaims
+	SessionID string `json:"sid,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Scope     string `json:"scope"`
+}
+
+// AccessToken is what an access token says about its bearer. ClientID is
+// set when the token was issued to an OAuth client.
+type AccessToken struct {
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+	ClientID  string
+	Scopes    []string
+}
+
 // MakeJWT -
 func MakeJWT(
 	userID uuid.UUID,
-	tokenSecret string,
+	keys *KeySet,
 	expiresIn time.Duration,
 ) (string, error) {
-	signingKey := []byte(tokenSecret)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    string(TokenTypeAccess),
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject:   userID.String(),
-	})
-	return token.SignedString(signingKey)
+	return MakeSessionJWT(userID, uuid.Nil, keys, expiresIn)
+}
+
+// MakeSessionJWT makes an access token with every session scope that
+// records the session it was issued for. A nil sessionID leaves the claim
+// out.
+func MakeSessionJWT(
+	userID uuid.UUID,
+	sessionID uuid.UUID,
+	keys *KeySet,
+	expiresIn time.Duration,
+) (string, error) {
+	return MakeAccessToken(AccessToken{
+		UserID:    userID,
+		SessionID: sessionID,
+		Scopes:    SessionScopes,
+	}, keys, expiresIn)
+}
+
+// MakeAccessToken makes an access token limited to token.Scopes.
+func MakeAccessToken(token AccessToken, keys *KeySet, expiresIn time.Duration) (string, error) {
+	claims := accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(TokenTypeAccess),
+			Audience:  jwt.ClaimStrings{AccessTokenAudience},
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   token.UserID.String(),
+		},
+		ClientID: token.ClientID,
+		Scope:    strings.Join(token.Scopes, " "),
+	}
+	if token.SessionID != uuid.Nil {
+		claims.SessionID = token.SessionID.String()
+	}
+	return keys.sign(TokenTypeAccess, claims)
 }
 
 // ValidateJWT -
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claimsStruct := jwt.RegisteredClaims{}
-	token, err := jwt.ParseWithClaims(
-		tokenString,
-		&claimsStruct,
-		func(token *jwt.Token) (interface{}, error) { return []byte(tokenSecret), nil },
-	)
+func ValidateJWT(tokenString string, keys *KeySet) (uuid.UUID, error) {
+	userID, _, err := ValidateSessionJWT(tokenString, keys)
+	return userID, err
+}
+
+// ValidateSessionJWT validates an access token and returns the user and
+// session it was issued for. The session is uuid.Nil for tokens made
+// without one.
+func ValidateSessionJWT(tokenString string, keys *KeySet) (uuid.UUID, uuid.UUID, error) {
+	token, err := ParseAccessToken(tokenString, keys)
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, uuid.Nil, err
+	}
+	return token.UserID, token.SessionID, nil
+}
+
+// ParseAccessToken validates an access token and returns who it was issued
+// to, for which session and with what scopes.
+func ParseAccessToken(tokenString string, keys *KeySet) (AccessToken, error) {
+	claimsStruct := accessClaims{}
+	token, err := keys.parse(tokenString, TokenTypeAccess, &claimsStruct, jwt.WithAudience(AccessTokenAudience))
+	if err != nil {
+		return AccessToken{}, err
 	}
 
 	userIDString, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.Nil, err
+		return AccessToken{}, err
 	}
 
 	issuer, err := token.Claims.GetIssuer()
 	if err != nil {
-		return uuid.Nil, err
+		return AccessToken{}, err
 	}
 	if issuer != string(TokenTypeAccess) {
-		return uuid.Nil, errors.New("invalid issuer")
+		return AccessToken{}, errors.New("invalid issuer")
 	}
 
 	id, err := uuid.Parse(userIDString)
+	if err != nil {
+		return AccessToken{}, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	sessionID := uuid.Nil
+	if claimsStruct.SessionID != "" {
+		sessionID, err = uuid.Parse(claimsStruct.SessionID)
+		if err != nil {
+			return AccessToken{}, fmt.Errorf("invalid session ID: %w", err)
+		}
+	}
+	return AccessToken{
+		UserID:    id,
+		SessionID: sessionID,
+		ClientID:  claimsStruct.ClientID,
+		Scopes:    strings.Fields(claimsStruct.Scope),
+	}, nil
+}
+
+// MakeChallengeJWT makes a token showing that userID got their password
+// right and still has to pass a second factor. It can't be used as an
+// access token.
+func MakeChallengeJWT(userID uuid.UUID, keys *KeySet, expiresIn time.Duration) (string, error) {
+	return keys.sign(TokenTypeChallenge, jwt.RegisteredClaims{
+		Issuer:    string(TokenTypeChallenge),
+		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+		Subject:   userID.String(),
+	})
+}
+
+// ValidateChallengeJWT -
+func ValidateChallengeJWT(tokenString string, keys *KeySet) (uuid.UUID, error) {
+	claims := jwt.RegisteredClaims{}
+	_, err := keys.parse(tokenString, TokenTypeChallenge, &claims)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if claims.Issuer != string(TokenTypeChallenge) {
+		return uuid.Nil, errors.New("invalid issuer")
+	}
+	id, err := uuid.Parse(claims.Subject)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("invalid user ID: %w", err)
 	}
 	return id, nil
 }
 
+// LoginState is what a browser must bring back from an external identity
+// provider to finish signing in.
+type LoginState struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+}
+
+type loginStateClaims struct {
+	jwt.RegisteredClaims
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// MakeLoginStateJWT packs state into a token for a cookie, so the callback
+// can check it came from the same browser that started the login.
+func MakeLoginStateJWT(state LoginState, keys *KeySet, expiresIn time.Duration) (string, error) {
+	return keys.sign(TokenTypeLoginState, loginStateClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(TokenTypeLoginState),
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+		},
+		State:        state.State,
+		Nonce:        state.Nonce,
+		CodeVerifier: state.CodeVerifier,
+	})
+}
+
+// ValidateLoginStateJWT -
+func ValidateLoginStateJWT(tokenString string, keys *KeySet) (LoginState, error) {
+	claims := loginStateClaims{}
+	_, err := keys.parse(tokenString, TokenTypeLoginState, &claims)
+	if err != nil {
+		return LoginState{}, err
+	}
+	if claims.Issuer != string(TokenTypeLoginState) {
+		return LoginState{}, errors.New("invalid issuer")
+	}
+	return LoginState{
+		State:        claims.State,
+		Nonce:        claims.Nonce,
+		CodeVerifier: claims.CodeVerifier,
+	}, nil
+}
+
 // GetBearerToken -
 func GetBearerToken(headers http.Header) (string, error) {
 	authHeader := headers.Get("Authorization")
@@ -100,6 +271,25 @@ func GetBearerToken(headers http.Header) (string, error) {
 	return splitAuth[1], nil
 }
 
+// APIKeyPrefix starts every API key, so keys can be told apart from JWTs
+// and spotted by secret scanners.
+const APIKeyPrefix = "chirpy_"
+
+// MakeAPIKey returns a new personal API key.
+func MakeAPIKey() (string, error) {
+	key, err := MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + key, nil
+}
+
+// IsAPIKey reports whether a bearer token looks like an API key rather
+// than a JWT.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
 func MakeRefreshToken() (string, error)  {
 	key := make([]byte, 32)
 	_, err := rand.Read(key)
@@ -110,6 +300,15 @@ func MakeRefreshToken() (string, error)  {
 	return encodedStr, nil
 }
 
+// HashToken returns the SHA-256 hex digest of a random token. Tokens that
+// are handed out once and looked up later are stored hashed so a database
+// leak doesn't give anyone usable tokens. Their entropy makes a slow hash
+// unnecessary.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func GetApiKey(headers http.Header) (string, error) {
 	authHeader := headers.Get("Authorization")
 	if authHeader == "" {
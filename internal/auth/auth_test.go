@@ -1,9 +1,11 @@
 package auth
 
 import (
+	"slices"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -65,33 +67,35 @@ func TestCheckPasswordHash(t *testing.T) {
 
 func TestValidateJWT(t *testing.T) {
 	userID := uuid.New()
-	validToken, _ := MakeJWT(userID, "secret", time.Hour)
+	keys := testKeySet(t)
+	otherKeys := testKeySet(t)
+	validToken, _ := MakeJWT(userID, keys, time.Hour)
 
 	tests := []struct {
 		name        string
 		tokenString string
-		tokenSecret string
+		keys        *KeySet
 		wantUserID  uuid.UUID
 		wantErr     bool
 	}{
 		{
 			name:        "Valid token",
 			tokenString: validToken,
-			tokenSecret: "secret",
+			keys:        keys,
 			wantUserID:  userID,
 			wantErr:     false,
 		},
 		{
 			name:        "Invalid token",
 			tokenString: "invalid.token.string",
-			tokenSecret: "secret",
+			keys:        keys,
 			wantUserID:  uuid.Nil,
 			wantErr:     true,
 		},
 		{
-			name:        "Wrong secret",
+			name:        "Wrong keys",
 			tokenString: validToken,
-			tokenSecret: "wrong_secret",
+			keys:        otherKeys,
 			wantUserID:  uuid.Nil,
 			wantErr:     true,
 		},
@@ -99,7 +103,7 @@ func TestValidateJWT(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotUserID, err := ValidateJWT(tt.tokenString, tt.tokenSecret)
+			gotUserID, err := ValidateJWT(tt.tokenString, tt.keys)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ValidateJWT() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -109,4 +113,104 @@ func TestValidateJWT(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestValidateSessionJWT(t *testing.T) {
+	userID := uuid.New()
+	sessionID := uuid.New()
+	keys := testKeySet(t)
+
+	token, _ := MakeSessionJWT(userID, sessionID, keys, time.Hour)
+	gotUserID, gotSessionID, err := ValidateSessionJWT(token, keys)
+	if err != nil {
+		t.Fatalf("ValidateSessionJWT() error = %v", err)
+	}
+	if gotUserID != userID || gotSessionID != sessionID {
+		t.Errorf("ValidateSessionJWT() = %v, %v, want %v, %v", gotUserID, gotSessionID, userID, sessionID)
+	}
+
+	token, _ = MakeJWT(userID, keys, time.Hour)
+	_, gotSessionID, err = ValidateSessionJWT(token, keys)
+	if err != nil {
+		t.Fatalf("ValidateSessionJWT() error = %v", err)
+	}
+	if gotSessionID != uuid.Nil {
+		t.Errorf("ValidateSessionJWT() session = %v, want uuid.Nil", gotSessionID)
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	// echo -n abc | sha256sum
+	want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := HashToken("abc"); got != want {
+		t.Errorf("HashToken() = %q, want %q", got, want)
+	}
+}
+
+func TestChallengeJWT(t *testing.T) {
+	userID := uuid.New()
+	keys := testKeySet(t)
+
+	challenge, _ := MakeChallengeJWT(userID, keys, time.Minute)
+	got, err := ValidateChallengeJWT(challenge, keys)
+	if err != nil || got != userID {
+		t.Errorf("ValidateChallengeJWT() = %v, %v, want %v", got, err, userID)
+	}
+	if _, err := ValidateJWT(challenge, keys); err == nil {
+		t.Error("ValidateJWT() accepted a challenge token")
+	}
+
+	access, _ := MakeJWT(userID, keys, time.Minute)
+	if _, err := ValidateChallengeJWT(access, keys); err == nil {
+		t.Error("ValidateChallengeJWT() accepted an access token")
+	}
+}
+
+// Other services only see the published keys, so the headers and claims
+// must tell access tokens apart from the rest.
+func TestTokenTypes(t *testing.T) {
+	keys := testKeySet(t)
+	access, _ := MakeJWT(uuid.New(), keys, time.Minute)
+	challenge, _ := MakeChallengeJWT(uuid.New(), keys, time.Minute)
+	state, _ := MakeLoginStateJWT(LoginState{State: "state"}, keys, time.Minute)
+
+	tests := []struct {
+		name       string
+		token      string
+		wantAccess bool
+	}{
+		{"Access", access, true},
+		{"Challenge", challenge, false},
+		{"Login state", state, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := jwt.RegisteredClaims{}
+			token, _, err := jwt.NewParser().ParseUnverified(tt.token, &claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			isAccess := token.Header["typ"] == AccessTokenHeaderType
+			hasAudience := slices.Contains(claims.Audience, AccessTokenAudience)
+			if isAccess != tt.wantAccess || hasAudience != tt.wantAccess {
+				t.Errorf("typ = %v, aud = %v", token.Header["typ"], claims.Audience)
+			}
+		})
+	}
+}
+
+func TestLoginStateJWT(t *testing.T) {
+	keys := testKeySet(t)
+	state := LoginState{State: "state", Nonce: "nonce", CodeVerifier: "verifier"}
+
+	token, _ := MakeLoginStateJWT(state, keys, time.Minute)
+	got, err := ValidateLoginStateJWT(token, keys)
+	if err != nil || got != state {
+		t.Errorf("ValidateLoginStateJWT() = %+v, %v, want %+v", got, err, state)
+	}
+
+	challenge, _ := MakeChallengeJWT(uuid.New(), keys, time.Minute)
+	if _, err := ValidateLoginStateJWT(challenge, keys); err == nil {
+		t.Error("ValidateLoginStateJWT() accepted a challenge token")
+	}
+}
@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const minRSAKeyBits = 2048
+
+// ErrUnknownKey -
+var ErrUnknownKey = errors.New("token signed with unknown key")
+
+// SigningKey is a private key that tokens are signed with. ID goes in the
+// kid header of every token it signs.
+type SigningKey struct {
+	ID  string
+	Key crypto.Signer
+}
+
+// KeySet holds the keys access tokens are signed and verified with. New
+// tokens are signed with the current key, but any key in the set verifies,
+// so a new key can take over while tokens signed by the old one are still
+// around.
+type KeySet struct {
+	current string
+	keys    map[string]SigningKey
+}
+
+// NewKeySet returns a KeySet that signs with the key named currentID.
+func NewKeySet(keys []SigningKey, currentID string) (*KeySet, error) {
+	ks := &KeySet{current: currentID, keys: make(map[string]SigningKey, len(keys))}
+	for _, key := range keys {
+		if _, err := signingMethod(key.Key); err != nil {
+			return nil, fmt.Errorf("key %q: %w", key.ID, err)
+		}
+		if _, ok := ks.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key ID %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+	}
+	if _, ok := ks.keys[currentID]; !ok {
+		return nil, fmt.Errorf("no key with ID %q", currentID)
+	}
+	return ks, nil
+}
+
+// LoadKeySet reads every <kid>.pem file in dir. The keys must be Ed25519
+// or RSA private keys in PKCS #8 or PKCS #1 form. currentID may only be
+// left empty if there is one key. Otherwise a key added to the directory
+// would sign straight away, before verifiers caching the JWKS had seen it.
+func LoadKeySet(dir, currentID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no .pem files in %s", dir)
+	}
+	sort.Strings(paths)
+
+	keys := make([]SigningKey, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := ParseSigningKey(id, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	if currentID == "" {
+		if len(keys) > 1 {
+			return nil, fmt.Errorf("%d keys in %s, so which one signs must be given", len(keys), dir)
+		}
+		currentID = keys[0].ID
+	}
+	return NewKeySet(keys, currentID)
+}
+
+// GenerateKeySet makes a KeySet with one new Ed25519 key. Tokens it signs
+// stop verifying when the process exits, so it's only fit for development.
+func GenerateKeySet() (*KeySet, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := base64.RawURLEncoding.EncodeToString(priv.Public().(ed25519.PublicKey)[:8])
+	return NewKeySet([]SigningKey{{ID: id, Key: priv}}, id)
+}
+
+// ParseSigningKey reads a PEM encoded private key.
+func ParseSigningKey(id string, data []byte) (SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return SigningKey{}, errors.New("no PEM data found")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return SigningKey{}, errors.New("not a signing key")
+	}
+	if _, err := signingMethod(signer); err != nil {
+		return SigningKey{}, err
+	}
+	return SigningKey{ID: id, Key: signer}, nil
+}
+
+func signingMethod(key crypto.Signer) (jwt.SigningMethod, error) {
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return jwt.SigningMethodEdDSA, nil
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA keys must be at least %d bits", minRSAKeyBits)
+		}
+		return jwt.SigningMethodRS256, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// sign makes a token of type typ, marked as such in its typ header.
+func (ks *KeySet) sign(typ TokenType, claims jwt.Claims) (string, error) {
+	key := ks.keys[ks.current]
+	method, err := signingMethod(key.Key)
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = key.ID
+	token.Header["typ"] = typ.headerType()
+	return token.SignedString(key.Key)
+}
+
+// parse verifies tokenString is a token of type typ, signed by the key its
+// kid header names and only with that key's algorithm, then fills in
+// claims.
+func (ks *KeySet) parse(tokenString string, typ TokenType, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	opts = append(opts, jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}))
+	return jwt.ParseWithClaims(
+		tokenString,
+		claims,
+		func(token *jwt.Token) (interface{}, error) {
+			if header, _ := token.Header["typ"].(string); header != typ.headerType() {
+				return nil, fmt.Errorf("unexpected token type %q", header)
+			}
+			kid, _ := token.Header["kid"].(string)
+			key, ok := ks.keys[kid]
+			if !ok {
+				return nil, ErrUnknownKey
+			}
+			method, err := signingMethod(key.Key)
+			if err != nil {
+				return nil, err
+			}
+			if token.Method.Alg() != method.Alg() {
+				return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+			}
+			return key.Key.Public(), nil
+		},
+		opts...,
+	)
+}
+
+// JWK is a public key in RFC 7517 JSON Web Key form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// PublicKey decodes the key, for verifying tokens signed by another
+// service. RSA, EC and Ed25519 keys are supported.
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid e")
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if pub.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA keys must be at least %d bits", minRSAKeyBits)
+		}
+		return pub, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			return nil, errors.New("invalid EC point")
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		// Make sure the point is on the curve before trusting it.
+		if _, err := pub.ECDH(); err != nil {
+			return nil, fmt.Errorf("invalid EC point: %w", err)
+		}
+		return pub, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public half of every key in the set, for services that
+// verify Chirpy tokens themselves.
+func (ks *KeySet) JWKS() JWKS {
+	ids := make([]string, 0, len(ks.keys))
+	for id := range ks.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	set := JWKS{Keys: make([]JWK, 0, len(ids))}
+	for _, id := range ids {
+		jwk := JWK{Kid: id, Use: "sig"}
+		switch pub := ks.keys[id].Key.Public().(type) {
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Alg = jwt.SigningMethodEdDSA.Alg()
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.Alg = jwt.SigningMethodRS256.Alg()
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
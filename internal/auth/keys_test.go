@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func testKeySet(t *testing.T) *KeySet {
+	t.Helper()
+	keys, err := GenerateKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func writeKey(t *testing.T, dir, id string, key any) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, id+".pem"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	_, oldKey, _ := ed25519.GenerateKey(rand.Reader)
+	writeKey(t, dir, "2026-01", oldKey)
+
+	before, err := LoadKeySet(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID := uuid.New()
+	oldToken, _ := MakeJWT(userID, before, time.Hour)
+
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKey(t, dir, "2026-02", newKey)
+
+	// With two keys the one that signs has to be named, so a new key isn't
+	// used before verifiers have fetched it.
+	if _, err := LoadKeySet(dir, ""); err == nil {
+		t.Error("LoadKeySet() with two keys and no key ID succeeded")
+	}
+	published, err := LoadKeySet(dir, "2026-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ValidateJWT(oldToken, published); err != nil || got != userID {
+		t.Errorf("ValidateJWT(old token) = %v, %v", got, err)
+	}
+	if len(published.JWKS().Keys) != 2 {
+		t.Errorf("JWKS() has %d keys, want both", len(published.JWKS().Keys))
+	}
+
+	after, err := LoadKeySet(dir, "2026-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tokens from before the rotation still verify.
+	if got, err := ValidateJWT(oldToken, after); err != nil || got != userID {
+		t.Errorf("ValidateJWT(old token) = %v, %v", got, err)
+	}
+
+	// New tokens are signed with the key named.
+	newToken, _ := MakeJWT(userID, after, time.Hour)
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &jwt.RegisteredClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header["kid"] != "2026-02" || parsed.Method.Alg() != "RS256" {
+		t.Errorf("new token header = %v, want kid 2026-02 and RS256", parsed.Header)
+	}
+
+	// Once the old key is dropped, its tokens stop verifying.
+	os.Remove(filepath.Join(dir, "2026-01.pem"))
+	dropped, err := LoadKeySet(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateJWT(oldToken, dropped); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("ValidateJWT(old token) error = %v, want ErrUnknownKey", err)
+	}
+
+	pinned, err := LoadKeySet(dir, "missing")
+	if err == nil {
+		t.Errorf("LoadKeySet() with unknown key ID = %v, want error", pinned)
+	}
+}
+
+func TestValidateJWTRejectsOtherAlgorithms(t *testing.T) {
+	keys := testKeySet(t)
+	userID := uuid.New()
+
+	// An HS256 token "signed" with the public key, under a valid kid, must
+	// not be accepted.
+	jwk := keys.JWKS().Keys[0]
+	pub := keys.keys[jwk.Kid].Key.Public().(ed25519.PublicKey)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    string(TokenTypeAccess),
+		Subject:   userID.String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	token.Header["kid"] = jwk.Kid
+	forged, err := token.SignedString([]byte(pub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateJWT(forged, keys); err == nil {
+		t.Error("ValidateJWT() accepted an HS256 token")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := NewKeySet([]SigningKey{{ID: "ed", Key: edKey}, {ID: "rsa", Key: rsaKey}}, "ed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := keys.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS() has %d keys, want 2", len(set.Keys))
+	}
+	ed, rs := set.Keys[0], set.Keys[1]
+	if ed.Kid != "ed" || ed.Kty != "OKP" || ed.Crv != "Ed25519" || ed.Alg != "EdDSA" || len(ed.X) != 43 {
+		t.Errorf("Ed25519 JWK = %+v", ed)
+	}
+	if rs.Kid != "rsa" || rs.Kty != "RSA" || rs.Alg != "RS256" || rs.E != "AQAB" || rs.N == "" {
+		t.Errorf("RSA JWK = %+v", rs)
+	}
+}
+
+func TestNewKeySetRejectsWeakKeys(t *testing.T) {
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewKeySet([]SigningKey{{ID: "weak", Key: weak}}, "weak"); err == nil {
+		t.Error("NewKeySet() accepted a 1024 bit RSA key")
+	}
+}
+
+func TestJWKPublicKey(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := NewKeySet([]SigningKey{{ID: "ed", Key: edKey}, {ID: "rsa", Key: rsaKey}}, "ed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := keys.JWKS()
+	for i, want := range []crypto.PublicKey{edKey.Public(), rsaKey.Public()} {
+		got, err := set.Keys[i].PublicKey()
+		if err != nil {
+			t.Fatalf("PublicKey() for %s: %v", set.Keys[i].Kid, err)
+		}
+		if !want.(interface{ Equal(crypto.PublicKey) bool }).Equal(got) {
+			t.Errorf("PublicKey() for %s doesn't round-trip", set.Keys[i].Kid)
+		}
+	}
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ec := JWK{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32))),
+	}
+	got, err := ec.PublicKey()
+	if err != nil || !ecKey.PublicKey.Equal(got) {
+		t.Errorf("EC PublicKey() = %v, %v", got, err)
+	}
+
+	ec.Y = ec.X
+	if _, err := ec.PublicKey(); err == nil {
+		t.Error("PublicKey() accepted a point off the curve")
+	}
+}
@@ -0,0 +1,28 @@
+package auth
+
+import "time"
+
+// LockoutPolicy decides how long to refuse logins after repeated failures.
+// The first Threshold failures are free; after that each failure doubles
+// the lockout, starting at Base and never exceeding Max.
+type LockoutPolicy struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+}
+
+// Delay returns how long to lock out after the given number of consecutive
+// failures, or zero if no lockout is due yet.
+func (p LockoutPolicy) Delay(failures int) time.Duration {
+	if failures < p.Threshold {
+		return 0
+	}
+	delay := p.Base
+	for i := p.Threshold; i < failures; i++ {
+		delay *= 2
+		if delay >= p.Max {
+			return p.Max
+		}
+	}
+	return min(delay, p.Max)
+}
@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutPolicyDelay(t *testing.T) {
+	policy := LockoutPolicy{Threshold: 5, Base: 30 * time.Second, Max: 10 * time.Minute}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: 4, want: 0},
+		{failures: 5, want: 30 * time.Second},
+		{failures: 6, want: time.Minute},
+		{failures: 7, want: 2 * time.Minute},
+		{failures: 9, want: 8 * time.Minute},
+		{failures: 10, want: 10 * time.Minute},
+		{failures: 1000, want: 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := policy.Delay(tt.failures); got != tt.want {
+			t.Errorf("Delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
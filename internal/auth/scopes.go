@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Scopes limit what an access token or API key can do.
+const (
+	ScopeChirpsRead   = "chirps:read"
+	ScopeChirpsWrite  = "chirps:write"
+	ScopeFollowsWrite = "follows:write"
+	ScopeProfileWrite = "profile:write"
+	// ScopeAccount covers managing the account itself: credentials,
+	// sessions, API keys, 2FA, exports and deletion. Only the user's own
+	// logins get it.
+	ScopeAccount = "account"
+)
+
+// SessionScopes are granted to tokens from logging in with a password.
+var SessionScopes = []string{
+	ScopeAccount,
+	ScopeChirpsRead,
+	ScopeChirpsWrite,
+	ScopeFollowsWrite,
+	ScopeProfileWrite,
+}
+
+// DelegableScopes are the scopes a user can hand to an API key or another
+// app.
+var DelegableScopes = []string{
+	ScopeChirpsRead,
+	ScopeChirpsWrite,
+	ScopeFollowsWrite,
+	ScopeProfileWrite,
+}
+
+// ParseScopes splits a space separated scope string, as used in OAuth2 and
+// the scope claim, and checks each scope is one of allowed. Duplicates are
+// dropped.
+func ParseScopes(s string, allowed []string) ([]string, error) {
+	return ValidateScopes(strings.Fields(s), allowed)
+}
+
+// ValidateScopes checks each scope is one of allowed and drops duplicates.
+func ValidateScopes(scopes []string, allowed []string) ([]string, error) {
+	valid := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !HasScope(allowed, scope) {
+			return nil, fmt.Errorf("invalid scope %q", scope)
+		}
+		if !HasScope(valid, scope) {
+			valid = append(valid, scope)
+		}
+	}
+	return valid, nil
+}
+
+// HasScope reports whether scopes includes scope.
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{"", []string{}, false},
+		{"chirps:read", []string{"chirps:read"}, false},
+		{" chirps:read  chirps:write chirps:read", []string{"chirps:read", "chirps:write"}, false},
+		{"chirps:read account", nil, true},
+		{"chirps:delete", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseScopes(tt.input, DelegableScopes)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseScopes(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseScopes(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestAccessTokenScopes(t *testing.T) {
+	keys := testKeySet(t)
+	userID := uuid.New()
+
+	token, _ := MakeAccessToken(AccessToken{UserID: userID, Scopes: []string{ScopeChirpsRead}}, keys, time.Minute)
+	got, err := ParseAccessToken(token, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UserID != userID || !reflect.DeepEqual(got.Scopes, []string{ScopeChirpsRead}) {
+		t.Errorf("ParseAccessToken() = %+v", got)
+	}
+
+	session, _ := MakeSessionJWT(userID, uuid.New(), keys, time.Minute)
+	got, err = ParseAccessToken(session, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Scopes, SessionScopes) {
+		t.Errorf("session token scopes = %v, want %v", got.Scopes, SessionScopes)
+	}
+}
+
+func TestIsAPIKey(t *testing.T) {
+	key, err := MakeAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsAPIKey(key) {
+		t.Errorf("IsAPIKey(%q) = false", key)
+	}
+	jwt, _ := MakeJWT(uuid.New(), testKeySet(t), time.Minute)
+	if IsAPIKey(jwt) {
+		t.Error("IsAPIKey() = true for a JWT")
+	}
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// TOTPDigits -
+	TOTPDigits = 6
+	// TOTPPeriod -
+	TOTPPeriod = 30 * time.Second
+	// totpSkew is how many periods either side of now a code is accepted
+	// for, to allow for clock drift and slow typing.
+	totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// HOTP computes an RFC 4226 one-time password.
+func HOTP(key []byte, counter uint64, digits int, h func() hash.Hash) string {
+	mac := hmac.New(h, key)
+	binary.Write(mac, binary.BigEndian, counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, code%mod)
+}
+
+// TOTPStep returns the RFC 6238 time step t falls in.
+func TOTPStep(t time.Time, period time.Duration) int64 {
+	return t.Unix() / int64(period/time.Second)
+}
+
+// TOTP computes an RFC 6238 time-based one-time password.
+func TOTP(key []byte, t time.Time, period time.Duration, digits int, h func() hash.Hash) string {
+	return HOTP(key, uint64(TOTPStep(t, period)), digits, h)
+}
+
+// MakeTOTPSecret returns a new random secret, base32 encoded as
+// authenticator apps expect.
+func MakeTOTPSecret() (string, error) {
+	key := make([]byte, 20)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(key), nil
+}
+
+// ValidateTOTP checks a six digit SHA-1 code, the kind authenticator apps
+// generate, against secret. It returns the time step the code was for so
+// callers can refuse to accept the same code twice.
+func ValidateTOTP(secret, code string, now time.Time) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != TOTPDigits {
+		return 0, false
+	}
+	step := TOTPStep(now, TOTPPeriod)
+	for i := -totpSkew; i <= totpSkew; i++ {
+		want := HOTP(key, uint64(step+int64(i)), TOTPDigits, sha1.New)
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return step + int64(i), true
+		}
+	}
+	return 0, false
+}
+
+// TOTPURI returns the otpauth:// URI authenticator apps read from QR codes.
+func TOTPURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTPDigits))
+	query.Set("period", fmt.Sprint(int(TOTPPeriod/time.Second)))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// MakeRecoveryCodes returns n single-use codes like "k3f9q-7xw2m" for when
+// a user can't get at their authenticator.
+func MakeRecoveryCodes(n int) ([]string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	codes := make([]string, n)
+	buf := make([]byte, 10)
+	for i := range codes {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		var sb strings.Builder
+		for j, b := range buf {
+			if j == 5 {
+				sb.WriteByte('-')
+			}
+			// 256 isn't a multiple of len(alphabet), but the bias is too
+			// small to matter for a code that can be tried a few times.
+			sb.WriteByte(alphabet[int(b)%len(alphabet)])
+		}
+		codes[i] = sb.String()
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode lets users type a recovery code without the dash
+// or in upper case.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	if len(code) != 10 {
+		return code
+	}
+	return code[:5] + "-" + code[5:]
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHOTP(t *testing.T) {
+	// RFC 4226 appendix D.
+	key := []byte("12345678901234567890")
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range want {
+		if got := HOTP(key, uint64(counter), 6, sha1.New); got != code {
+			t.Errorf("HOTP(%d) = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 appendix B.
+	keys := []struct {
+		name string
+		key  []byte
+		hash func() hash.Hash
+	}{
+		{"SHA1", []byte("12345678901234567890"), sha1.New},
+		{"SHA256", []byte("12345678901234567890123456789012"), sha256.New},
+		{"SHA512", []byte("1234567890123456789012345678901234567890123456789012345678901234"), sha512.New},
+	}
+	tests := []struct {
+		unix int64
+		want [3]string
+	}{
+		{59, [3]string{"94287082", "46119246", "90693936"}},
+		{1111111109, [3]string{"07081804", "68084774", "25091201"}},
+		{1111111111, [3]string{"14050471", "67062674", "99943326"}},
+		{1234567890, [3]string{"89005924", "91819424", "93441116"}},
+		{2000000000, [3]string{"69279037", "90698825", "38618901"}},
+		{20000000000, [3]string{"65353130", "77737706", "47863826"}},
+	}
+
+	for _, tt := range tests {
+		for i, k := range keys {
+			got := TOTP(k.key, time.Unix(tt.unix, 0), 30*time.Second, 8, k.hash)
+			if got != tt.want[i] {
+				t.Errorf("TOTP(%s, %d) = %s, want %s", k.name, tt.unix, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	// "12345678901234567890" in base32.
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1111111111, 0)
+	current := TOTP([]byte("12345678901234567890"), now, TOTPPeriod, TOTPDigits, sha1.New)
+	previous := TOTP([]byte("12345678901234567890"), now.Add(-TOTPPeriod), TOTPPeriod, TOTPDigits, sha1.New)
+	stale := TOTP([]byte("12345678901234567890"), now.Add(-3*TOTPPeriod), TOTPPeriod, TOTPDigits, sha1.New)
+
+	if step, ok := ValidateTOTP(secret, current, now); !ok || step != TOTPStep(now, TOTPPeriod) {
+		t.Errorf("ValidateTOTP(current) = %d, %v", step, ok)
+	}
+	if step, ok := ValidateTOTP(strings.ToLower(secret), previous, now); !ok || step != TOTPStep(now, TOTPPeriod)-1 {
+		t.Errorf("ValidateTOTP(previous) = %d, %v", step, ok)
+	}
+	if _, ok := ValidateTOTP(secret, stale, now); ok {
+		t.Error("ValidateTOTP(stale) accepted a code from three periods ago")
+	}
+	if _, ok := ValidateTOTP(secret, "12345", now); ok {
+		t.Error("ValidateTOTP() accepted a short code")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	got := TOTPURI("Chirpy", "boots@example.com", "JBSWY3DPEHPK3PXP")
+	want := "otpauth://totp/Chirpy:boots@example.com?algorithm=SHA1&digits=6&issuer=Chirpy&period=30&secret=JBSWY3DPEHPK3PXP"
+	if got != want {
+		t.Errorf("TOTPURI() = %s, want %s", got, want)
+	}
+}
+
+func TestMakeRecoveryCodes(t *testing.T) {
+	codes, err := MakeRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("code %q isn't in xxxxx-xxxxx form", code)
+		}
+		if NormalizeRecoveryCode(strings.ToUpper(strings.ReplaceAll(code, "-", ""))) != code {
+			t.Errorf("NormalizeRecoveryCode() didn't restore %q", code)
+		}
+		seen[code] = true
+	}
+	if len(seen) != len(codes) {
+		t.Error("MakeRecoveryCodes() returned duplicates")
+	}
+}
@@ -6,33 +6,230 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type ApiKey struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	Label      string
+	KeyHash    string
+	Prefix     string
+	Scopes     []string
+	ExpiresAt  time.Time
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
 type Chirp struct {
-	ID        uuid.UUID
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Body          string
+	UserID        uuid.NullUUID
+	InReplyToID   uuid.NullUUID
+	ReplyCount    int32
+	DeletedAt     sql.NullTime
+	LikeCount     int32
+	RechirpOfID   uuid.NullUUID
+	QuotedChirpID uuid.NullUUID
+	SearchVector  interface{}
+	EditedAt      sql.NullTime
+}
+
+type ChirpHashtag struct {
+	ChirpID     uuid.UUID
+	Tag         string
+	StartOffset int32
+	EndOffset   int32
+	CreatedAt   time.Time
+}
+
+type ChirpMedium struct {
+	ChirpID  uuid.UUID
+	MediaID  uuid.UUID
+	Position int32
+}
+
+type ChirpMention struct {
+	ChirpID     uuid.UUID
+	Handle      string
+	UserID      uuid.NullUUID
+	StartOffset int32
+	EndOffset   int32
+}
+
+type ChirpModeration struct {
+	ChirpID    uuid.UUID
+	Action     string
+	Verdicts   json.RawMessage
+	CreatedAt  time.Time
+	ReviewedAt sql.NullTime
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	Body       string
+	CreatedAt  time.Time
+	ReplacedAt time.Time
+}
+
+type DataExport struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Status     string
+	StorageKey sql.NullString
+	ExpiresAt  sql.NullTime
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Like struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type LoginFailure struct {
+	Key          string
+	Failures     int32
+	LastFailedAt time.Time
+	LockedUntil  sql.NullTime
+}
+
+type Medium struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	StorageKey   string
+	ThumbnailKey string
+	ContentType  string
+	SizeBytes    int32
+	Width        int32
+	Height       int32
+}
+
+type ModerationWord struct {
+	Word      string
+	Action    string
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	Body      string
+}
+
+type OauthClient struct {
+	ID           string
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris []string
+	Scopes       []string
+}
+
+type OauthCode struct {
+	CodeHash      string
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+	ClientID      string
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scopes        []string
+	CodeChallenge string
+}
+
+type RecoveryCode struct {
+	CodeHash  string
 	UserID    uuid.UUID
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
 }
 
 type RefreshToken struct {
-	Token     string
+	Token       string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	ExpiresAt   time.Time
+	RevokedAt   sql.NullTime
+	UserID      uuid.UUID
+	ParentToken sql.NullString
+	RotatedAt   sql.NullTime
+	SessionID   uuid.UUID
+	UserAgent   string
+	IpAddress   string
+	ClientID    sql.NullString
+	Scopes      []string
+}
+
+type Subscription struct {
+	UserID           uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Plan             string
+	Status           string
+	CurrentPeriodEnd time.Time
+	CanceledAt       sql.NullTime
+	GracePeriodEnd   sql.NullTime
+	LastEventID      uuid.NullUUID
+	LastEventAt      sql.NullTime
+}
+
+type User struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	Handle          sql.NullString
+	DisplayName     string
+	Bio             string
+	Location        string
+	AvatarMediaID   uuid.NullUUID
+	EmailVerifiedAt sql.NullTime
+	DeletedAt       sql.NullTime
+	TotpSecret      sql.NullString
+	TotpEnabledAt   sql.NullTime
+	TotpLastStep    int64
+}
+
+type UserIdentity struct {
+	Issuer    string
+	Subject   string
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Email     string
+}
+
+type UserToken struct {
+	TokenHash string
 	CreatedAt time.Time
-	UpdatedAt time.Time
 	ExpiresAt time.Time
-	RevokedAt sql.NullTime
+	UsedAt    sql.NullTime
 	UserID    uuid.UUID
+	Purpose   string
 }
 
-type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    sql.NullBool
+type WebhookEvent struct {
+	ID          uuid.UUID
+	ReceivedAt  time.Time
+	Source      string
+	EventID     string
+	EventType   string
+	Payload     json.RawMessage
+	Status      string
+	Error       sql.NullString
+	Attempts    int32
+	ProcessedAt sql.NullTime
+	ClaimedAt   sql.NullTime
 }
@@ -11,23 +11,31 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, in_reply_to_id, quoted_chirp_id)
 VALUES(
-    gen_random_uuid(), NOW(), NOW(), $1, $2
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to_id, reply_count, deleted_at, like_count, rechirp_of_id, quoted_chirp_id, search_vector, edited_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body          string
+	UserID        uuid.NullUUID
+	InReplyToID   uuid.NullUUID
+	QuotedChirpID uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.InReplyToID,
+		arg.QuotedChirpID,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -35,23 +43,37 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyToID,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpOfID,
+		&i.QuotedChirpID,
+		&i.SearchVector,
+		&i.EditedAt,
 	)
 	return i, err
 }
 
 const createRefreshToken = `-- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens(token, created_at, updated_at, expires_at, revoked_at, user_id)
+INSERT INTO refresh_tokens(token, created_at, updated_at, expires_at, revoked_at, user_id, parent_token, session_id, user_agent, ip_address, client_id, scopes)
 VALUES (
-    $1, NOw(), NOw(), $2, $3, $4
+    $1, NOw(), NOw(), $2, $3, $4, $5, $6, $7, $8, $9, $10
 )
-RETURNING token, created_at, updated_at, expires_at, revoked_at, user_id
+RETURNING token, created_at, updated_at, expires_at, revoked_at, user_id, parent_token, rotated_at, session_id, user_agent, ip_address, client_id, scopes
 `
 
 type CreateRefreshTokenParams struct {
-	Token     string
-	ExpiresAt time.Time
-	RevokedAt sql.NullTime
-	UserID    uuid.UUID
+	Token       string
+	ExpiresAt   time.Time
+	RevokedAt   sql.NullTime
+	UserID      uuid.UUID
+	ParentToken sql.NullString
+	SessionID   uuid.UUID
+	UserAgent   string
+	IpAddress   string
+	ClientID    sql.NullString
+	Scopes      []string
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
@@ -60,6 +82,12 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 		arg.ExpiresAt,
 		arg.RevokedAt,
 		arg.UserID,
+		arg.ParentToken,
+		arg.SessionID,
+		arg.UserAgent,
+		arg.IpAddress,
+		arg.ClientID,
+		pq.Array(arg.Scopes),
 	)
 	var i RefreshToken
 	err := row.Scan(
@@ -69,6 +97,13 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 		&i.ExpiresAt,
 		&i.RevokedAt,
 		&i.UserID,
+		&i.ParentToken,
+		&i.RotatedAt,
+		&i.SessionID,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
 	)
 	return i, err
 }
@@ -78,7 +113,7 @@ INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (
     gen_random_uuid(), NOW(), NOw(), $1, $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, handle, display_name, bio, location, avatar_media_id, email_verified_at, deleted_at, totp_secret, totp_enabled_at, totp_last_step
 `
 
 type CreateUserParams struct {
@@ -95,7 +130,16 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.AvatarMediaID,
+		&i.EmailVerifiedAt,
+		&i.DeletedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
 	)
 	return i, err
 }
@@ -103,12 +147,12 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 const deleteChirp = `-- name: DeleteChirp :one
 DELETE FROM chirps
 WHERE id = $1 AND user_id = $2
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to_id, reply_count, deleted_at, like_count, rechirp_of_id, quoted_chirp_id, search_vector, edited_at
 `
 
 type DeleteChirpParams struct {
 	ID     uuid.UUID
-	UserID uuid.UUID
+	UserID uuid.NullUUID
 }
 
 func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) (Chirp, error) {
@@ -120,6 +164,14 @@ func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyToID,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpOfID,
+		&i.QuotedChirpID,
+		&i.SearchVector,
+		&i.EditedAt,
 	)
 	return i, err
 }
@@ -134,7 +186,7 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, in_reply_to_id, reply_count, deleted_at, like_count, rechirp_of_id, quoted_chirp_id, search_vector, edited_at FROM chirps
 WHERE id = $1 LIMIT 1
 `
 
@@ -147,12 +199,20 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyToID,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpOfID,
+		&i.QuotedChirpID,
+		&i.SearchVector,
+		&i.EditedAt,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, in_reply_to_id, reply_count, deleted_at, like_count, rechirp_of_id, quoted_chirp_id, search_vector, edited_at FROM chirps
 ORDER BY created_at ASC
 `
 
@@ -171,6 +231,14 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyToID,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpOfID,
+			&i.QuotedChirpID,
+			&i.SearchVector,
+			&i.EditedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -186,7 +254,7 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getRefreshToken = `-- name: GetRefreshToken :one
-SELECT token, created_at, updated_at, expires_at, revoked_at, user_id FROM refresh_tokens
+SELECT token, created_at, updated_at, expires_at, revoked_at, user_id, parent_token, rotated_at, session_id, user_agent, ip_address, client_id, scopes FROM refresh_tokens
 WHERE token = $1 LIMIT 1
 `
 
@@ -200,12 +268,19 @@ func (q *Queries) GetRefreshToken(ctx context.Context, token string) (RefreshTok
 		&i.ExpiresAt,
 		&i.RevokedAt,
 		&i.UserID,
+		&i.ParentToken,
+		&i.RotatedAt,
+		&i.SessionID,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, handle, display_name, bio, location, avatar_media_id, email_verified_at, deleted_at, totp_secret, totp_enabled_at, totp_last_step FROM users
 WHERE email = $1 LIMIT 1
 `
 
@@ -218,7 +293,160 @@ func (q *Queries) GetUser(ctx context.Context, email string) (User, error) {
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.AvatarMediaID,
+		&i.EmailVerifiedAt,
+		&i.DeletedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const getUserByHandle = `-- name: GetUserByHandle :one
+SELECT id, created_at, updated_at, email, hashed_password, handle, display_name, bio, location, avatar_media_id, email_verified_at, deleted_at, totp_secret, totp_enabled_at, totp_last_step FROM users
+WHERE lower(handle) = lower($1) LIMIT 1
+`
+
+func (q *Queries) GetUserByHandle(ctx context.Context, lower string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByHandle, lower)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.AvatarMediaID,
+		&i.EmailVerifiedAt,
+		&i.DeletedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, handle, display_name, bio, location, avatar_media_id, email_verified_at, deleted_at, totp_secret, totp_enabled_at, totp_last_step FROM users
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.AvatarMediaID,
+		&i.EmailVerifiedAt,
+		&i.DeletedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const listUserProfiles = `-- name: ListUserProfiles :many
+SELECT users.id, users.handle, users.display_name, media.thumbnail_key AS avatar_key FROM users
+LEFT JOIN media ON media.id = users.avatar_media_id
+WHERE users.id = ANY($1::uuid[])
+AND users.deleted_at IS NULL
+`
+
+type ListUserProfilesRow struct {
+	ID          uuid.UUID
+	Handle      sql.NullString
+	DisplayName string
+	AvatarKey   sql.NullString
+}
+
+func (q *Queries) ListUserProfiles(ctx context.Context, ids []uuid.UUID) ([]ListUserProfilesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUserProfiles, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserProfilesRow
+	for rows.Next() {
+		var i ListUserProfilesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Handle,
+			&i.DisplayName,
+			&i.AvatarKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeRefreshTokenDescendants = `-- name: RevokeRefreshTokenDescendants :exec
+WITH RECURSIVE descendants AS (
+    SELECT token FROM refresh_tokens
+    WHERE parent_token = $1
+    UNION
+    SELECT rt.token FROM refresh_tokens rt
+    JOIN descendants d ON rt.parent_token = d.token
+)
+UPDATE refresh_tokens
+SET updated_at = NOW(), revoked_at = NOW()
+WHERE token IN (SELECT token FROM descendants) AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeRefreshTokenDescendants(ctx context.Context, parentToken sql.NullString) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshTokenDescendants, parentToken)
+	return err
+}
+
+const rotateRefreshToken = `-- name: RotateRefreshToken :one
+UPDATE refresh_tokens
+SET updated_at = NOW(), rotated_at = NOW()
+WHERE token = $1 AND rotated_at IS NULL AND revoked_at IS NULL
+RETURNING token, created_at, updated_at, expires_at, revoked_at, user_id, parent_token, rotated_at, session_id, user_agent, ip_address, client_id, scopes
+`
+
+func (q *Queries) RotateRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, rotateRefreshToken, token)
+	var i RefreshToken
+	err := row.Scan(
+		&i.Token,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.UserID,
+		&i.ParentToken,
+		&i.RotatedAt,
+		&i.SessionID,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
 	)
 	return i, err
 }
@@ -236,9 +464,10 @@ func (q *Queries) UpdateRefreshToken(ctx context.Context, token string) error {
 
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
-SET email = $1, hashed_password = $2
+SET email = $1, hashed_password = $2, updated_at = NOW(),
+    email_verified_at = CASE WHEN email = $1 THEN email_verified_at ELSE NULL END
 WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, handle, display_name, bio, location, avatar_media_id, email_verified_at, deleted_at, totp_secret, totp_enabled_at, totp_last_step
 `
 
 type UpdateUserParams struct {
@@ -256,25 +485,45 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.AvatarMediaID,
+		&i.EmailVerifiedAt,
+		&i.DeletedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
 	)
 	return i, err
 }
 
-const updateUserMembership = `-- name: UpdateUserMembership :one
+const updateUserProfile = `-- name: UpdateUserProfile :one
 UPDATE users
-SET is_chirpy_red = $1
-WHERE id = $2
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SET handle = $1, display_name = $2, bio = $3, location = $4, avatar_media_id = $5, updated_at = NOW()
+WHERE id = $6
+RETURNING id, created_at, updated_at, email, hashed_password, handle, display_name, bio, location, avatar_media_id, email_verified_at, deleted_at, totp_secret, totp_enabled_at, totp_last_step
 `
 
-type UpdateUserMembershipParams struct {
-	IsChirpyRed sql.NullBool
-	ID          uuid.UUID
+type UpdateUserProfileParams struct {
+	Handle        sql.NullString
+	DisplayName   string
+	Bio           string
+	Location      string
+	AvatarMediaID uuid.NullUUID
+	ID            uuid.UUID
 }
 
-func (q *Queries) UpdateUserMembership(ctx context.Context, arg UpdateUserMembershipParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUserMembership, arg.IsChirpyRed, arg.ID)
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+		arg.Location,
+		arg.AvatarMediaID,
+		arg.ID,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -282,7 +531,16 @@ func (q *Queries) UpdateUserMembership(ctx context.Context, arg UpdateUserMember
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.AvatarMediaID,
+		&i.EmailVerifiedAt,
+		&i.DeletedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
 	)
 	return i, err
 }
@@ -0,0 +1,88 @@
+package entities
+
+import (
+	"strings"
+	"unicode"
+)
+
+type Type string
+
+const (
+	// TypeHashtag -
+	TypeHashtag Type = "hashtag"
+	// TypeMention -
+	TypeMention Type = "mention"
+)
+
+// Entity is a hashtag or mention found in a chirp body. Start and End are
+// character (rune) offsets into the body, with End exclusive, and Text is
+// the tag or handle without its leading sigil.
+type Entity struct {
+	Type  Type
+	Text  string
+	Start int
+	End   int
+}
+
+// Parse returns the hashtags and mentions in body in the order they appear.
+// A sigil only starts an entity at the beginning of the body or after a
+// character that can't be part of a word, so email addresses and things like
+// "C#" are left alone.
+func Parse(body string) []Entity {
+	runes := []rune(body)
+	found := []Entity{}
+	for i := 0; i < len(runes); i++ {
+		var typ Type
+		switch runes[i] {
+		case '#':
+			typ = TypeHashtag
+		case '@':
+			typ = TypeMention
+		default:
+			continue
+		}
+		if i > 0 && isEntityRune(typ, runes[i-1]) {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isEntityRune(typ, runes[end]) {
+			end++
+		}
+		text := string(runes[i+1 : end])
+		if !valid(typ, text) {
+			continue
+		}
+		found = append(found, Entity{
+			Type:  typ,
+			Text:  text,
+			Start: i,
+			End:   end,
+		})
+		i = end - 1
+	}
+	return found
+}
+
+// NormalizeTag folds a hashtag so #Go and #go are the same tag.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+func isEntityRune(typ Type, r rune) bool {
+	if typ == TypeMention {
+		return r == '_' || (r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)))
+	}
+	return r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r)
+}
+
+func valid(typ Type, text string) bool {
+	if text == "" {
+		return false
+	}
+	if typ == TypeHashtag {
+		// #1 is a number, not a tag.
+		return strings.IndexFunc(text, func(r rune) bool { return !unicode.IsDigit(r) }) >= 0
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []Entity
+	}{
+		{
+			name: "No entities",
+			body: "just a chirp",
+			want: []Entity{},
+		},
+		{
+			name: "Hashtag and mention",
+			body: "hey @boots check #golang",
+			want: []Entity{
+				{Type: TypeMention, Text: "boots", Start: 4, End: 10},
+				{Type: TypeHashtag, Text: "golang", Start: 17, End: 24},
+			},
+		},
+		{
+			name: "Trailing punctuation",
+			body: "#go! and @dev.",
+			want: []Entity{
+				{Type: TypeHashtag, Text: "go", Start: 0, End: 3},
+				{Type: TypeMention, Text: "dev", Start: 9, End: 13},
+			},
+		},
+		{
+			name: "Offsets count characters not bytes",
+			body: "café #naïve",
+			want: []Entity{
+				{Type: TypeHashtag, Text: "naïve", Start: 5, End: 11},
+			},
+		},
+		{
+			name: "Email address is not a mention",
+			body: "mail me at boots@example.com",
+			want: []Entity{},
+		},
+		{
+			name: "Sigil inside a word",
+			body: "I like C# and F#sharp",
+			want: []Entity{},
+		},
+		{
+			name: "Numbers are not hashtags",
+			body: "we're #1 #2024goals",
+			want: []Entity{
+				{Type: TypeHashtag, Text: "2024goals", Start: 9, End: 19},
+			},
+		},
+		{
+			name: "Lone sigils",
+			body: "# @ ##",
+			want: []Entity{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.body)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{tag: "GoLang", want: "golang"},
+		{tag: "#Go", want: "go"},
+		{tag: "ÉTÉ", want: "été"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			if got := NormalizeTag(tt.tag); got != tt.want {
+				t.Errorf("NormalizeTag() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package entities
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	// MinHandleLength -
+	MinHandleLength = 3
+	// MaxHandleLength -
+	MaxHandleLength = 15
+)
+
+var (
+	// ErrHandleLength -
+	ErrHandleLength = errors.New("handle must be between 3 and 15 characters")
+	// ErrHandleChars -
+	ErrHandleChars = errors.New("handle may only contain letters, numbers and underscores")
+	// ErrHandleReserved -
+	ErrHandleReserved = errors.New("handle is reserved")
+)
+
+// reservedHandles can't be claimed because they'd be confused with the
+// service itself or collide with paths clients build from handles.
+var reservedHandles = map[string]struct{}{
+	"about":     {},
+	"admin":     {},
+	"api":       {},
+	"app":       {},
+	"chirpy":    {},
+	"help":      {},
+	"login":     {},
+	"logout":    {},
+	"me":        {},
+	"media":     {},
+	"mod":       {},
+	"moderator": {},
+	"null":      {},
+	"root":      {},
+	"search":    {},
+	"security":  {},
+	"settings":  {},
+	"signup":    {},
+	"staff":     {},
+	"support":   {},
+	"system":    {},
+	"timeline":  {},
+	"undefined": {},
+}
+
+// ValidateHandle checks that handle can be mentioned in a chirp and isn't
+// reserved. Handles are compared case-insensitively, so "Admin" is as
+// reserved as "admin".
+func ValidateHandle(handle string) error {
+	if len(handle) < MinHandleLength || len(handle) > MaxHandleLength {
+		return ErrHandleLength
+	}
+	for _, r := range handle {
+		if !isEntityRune(TypeMention, r) {
+			return ErrHandleChars
+		}
+	}
+	if _, ok := reservedHandles[NormalizeHandle(handle)]; ok {
+		return ErrHandleReserved
+	}
+	return nil
+}
+
+// NormalizeHandle folds a handle so @Boots and @boots are the same user.
+func NormalizeHandle(handle string) string {
+	return strings.ToLower(strings.TrimPrefix(handle, "@"))
+}
@@ -0,0 +1,32 @@
+package entities
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateHandle(t *testing.T) {
+	tests := []struct {
+		name    string
+		handle  string
+		wantErr error
+	}{
+		{name: "Valid", handle: "boots_42"},
+		{name: "Mixed case", handle: "BootsTheBear"},
+		{name: "Too short", handle: "ab", wantErr: ErrHandleLength},
+		{name: "Too long", handle: "abcdefghijklmnop", wantErr: ErrHandleLength},
+		{name: "Hyphen", handle: "boots-bear", wantErr: ErrHandleChars},
+		{name: "Non-ASCII", handle: "bóots", wantErr: ErrHandleChars},
+		{name: "Reserved", handle: "admin", wantErr: ErrHandleReserved},
+		{name: "Reserved in another case", handle: "Support", wantErr: ErrHandleReserved},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateHandle(tt.handle)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateHandle(%q) error = %v, want %v", tt.handle, err, tt.wantErr)
+			}
+		})
+	}
+}
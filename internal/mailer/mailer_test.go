@@ -0,0 +1,107 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	msg := Message{To: "boots@example.com", Subject: "Hello", Body: "line one\nline two"}
+
+	data, err := Format("chirpy@example.com", msg, date)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		"From: chirpy@example.com\r\n",
+		"To: boots@example.com\r\n",
+		"Subject: Hello\r\n",
+		"Date: Tue, 02 Jan 2024 03:04:05 +0000\r\n",
+		"\r\n\r\nline one\r\nline two",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Format() = %q, missing %q", got, want)
+		}
+	}
+}
+
+func TestFormatRejectsHeaderInjection(t *testing.T) {
+	msg := Message{To: "boots@example.com\r\nBcc: everyone@example.com", Subject: "Hi"}
+	if _, err := Format("chirpy@example.com", msg, time.Now()); err == nil {
+		t.Error("Format() error = nil, want an error for a recipient with a line break")
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer(dir, "chirpy@example.com")
+	if err != nil {
+		t.Fatalf("NewFileMailer() error = %v", err)
+	}
+
+	msg := Message{To: "boots@example.com", Subject: "Verify", Body: "token: abc"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("got %d files, want 1", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(data), "token: abc") {
+		t.Errorf("file contents = %q, missing body", data)
+	}
+}
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewLogMailer(&buf)
+	msg := Message{To: "boots@example.com", Subject: "Reset", Body: "token: xyz"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "boots@example.com") || !strings.Contains(buf.String(), "token: xyz") {
+		t.Errorf("log output = %q", buf.String())
+	}
+}
+
+func TestSMTPMailerGivesUpWithContext(t *testing.T) {
+	// A server that accepts the connection but never greets.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	m := NewSMTPMailer(ln.Addr().String(), "", "", "chirpy@example.com")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = m.Send(ctx, Message{To: "boots@example.com", Subject: "Hi", Body: "hello"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Send() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Send() took %s", elapsed)
+	}
+}
@@ -0,0 +1,74 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errMalformedGIF = errors.New("malformed GIF")
+
+// gifPixels adds up the area of every frame in a GIF by walking its blocks,
+// without decoding any image data.
+func gifPixels(data []byte) (int, error) {
+	// Header and logical screen descriptor.
+	const headerLen = 13
+	if len(data) < headerLen {
+		return 0, errMalformedGIF
+	}
+	pos := headerLen
+	if flags := data[10]; flags&0x80 != 0 {
+		pos += 3 << ((flags & 0x07) + 1)
+	}
+
+	pixels := 0
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21: // Extension: label, then sub-blocks.
+			var err error
+			if pos, err = skipSubBlocks(data, pos+2); err != nil {
+				return 0, err
+			}
+		case 0x2c: // Image descriptor.
+			if pos+10 > len(data) {
+				return 0, errMalformedGIF
+			}
+			w := int(binary.LittleEndian.Uint16(data[pos+5:]))
+			h := int(binary.LittleEndian.Uint16(data[pos+7:]))
+			flags := data[pos+9]
+			pixels += w * h
+			if pixels > MaxPixels {
+				return pixels, nil
+			}
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << ((flags & 0x07) + 1)
+			}
+			// LZW minimum code size, then the image data sub-blocks.
+			var err error
+			if pos, err = skipSubBlocks(data, pos+1); err != nil {
+				return 0, err
+			}
+		case 0x3b: // Trailer.
+			return pixels, nil
+		default:
+			return 0, errMalformedGIF
+		}
+	}
+	return pixels, nil
+}
+
+// skipSubBlocks returns the position just past the sub-blocks starting at
+// pos and the zero-length block that ends them.
+func skipSubBlocks(data []byte, pos int) (int, error) {
+	for {
+		if pos >= len(data) {
+			return 0, errMalformedGIF
+		}
+		n := int(data[pos])
+		pos++
+		if n == 0 {
+			return pos, nil
+		}
+		pos += n
+	}
+}
@@ -0,0 +1,186 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+const (
+	// ThumbnailSize is the longest edge of a generated thumbnail.
+	ThumbnailSize = 320
+	// MaxDimension bounds either edge of an upload so a small file can't
+	// decode into a huge bitmap.
+	MaxDimension = 8192
+	// MaxPixels bounds the total area of an upload, across every frame of
+	// an animation, since each decoded frame is held in memory at once.
+	MaxPixels = 40_000_000
+
+	jpegQuality = 90
+)
+
+// ErrUnsupportedType is returned for uploads that aren't an allowed image type.
+var ErrUnsupportedType = errors.New("unsupported media type")
+
+// ErrTooLarge is returned for images whose dimensions exceed MaxDimension or
+// whose frames add up to more than MaxPixels.
+var ErrTooLarge = errors.New("image dimensions are too large")
+
+var allowedTypes = map[string]struct{}{
+	"image/jpeg": {},
+	"image/png":  {},
+	"image/gif":  {},
+}
+
+// Processed is an upload that has been checked and re-encoded, along with a
+// thumbnail of it.
+type Processed struct {
+	ContentType string
+	Data        []byte
+	Thumbnail   []byte
+	Width       int
+	Height      int
+}
+
+// Process checks that data is a supported image and re-encodes it. Decoding
+// and encoding again drops EXIF and any other metadata the original carried,
+// including location data, so a JPEG's EXIF orientation is applied to the
+// pixels first. The type is sniffed from the bytes rather than trusted from
+// the client.
+func Process(data []byte) (Processed, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := allowedTypes[contentType]; !ok {
+		return Processed{}, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Processed{}, fmt.Errorf("couldn't read image: %w", err)
+	}
+	if config.Width > MaxDimension || config.Height > MaxDimension {
+		return Processed{}, ErrTooLarge
+	}
+	pixels := config.Width * config.Height
+	if contentType == "image/gif" {
+		if pixels, err = gifPixels(data); err != nil {
+			return Processed{}, fmt.Errorf("couldn't read image: %w", err)
+		}
+	}
+	if pixels > MaxPixels {
+		return Processed{}, ErrTooLarge
+	}
+
+	width, height := config.Width, config.Height
+	var out bytes.Buffer
+	var first image.Image
+	switch contentType {
+	case "image/gif":
+		// Keep every frame so animations survive.
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return Processed{}, fmt.Errorf("couldn't decode image: %w", err)
+		}
+		if err := gif.EncodeAll(&out, g); err != nil {
+			return Processed{}, err
+		}
+		first = g.Image[0]
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Processed{}, fmt.Errorf("couldn't decode image: %w", err)
+		}
+		if err := png.Encode(&out, img); err != nil {
+			return Processed{}, err
+		}
+		first = img
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Processed{}, fmt.Errorf("couldn't decode image: %w", err)
+		}
+		img = orient(img, jpegOrientation(data))
+		width, height = img.Bounds().Dx(), img.Bounds().Dy()
+		if err := jpeg.Encode(&out, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return Processed{}, err
+		}
+		first = img
+	}
+
+	var thumb bytes.Buffer
+	if err := jpeg.Encode(&thumb, Thumbnail(first, ThumbnailSize), &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return Processed{}, err
+	}
+
+	return Processed{
+		ContentType: contentType,
+		Data:        out.Bytes(),
+		Thumbnail:   thumb.Bytes(),
+		Width:       width,
+		Height:      height,
+	}, nil
+}
+
+// ReadAll reads at most limit bytes from r and fails if there are more.
+func ReadAll(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("file is larger than %d bytes", limit)
+	}
+	return data, nil
+}
+
+// Thumbnail scales img down so its longest edge is at most size, averaging
+// the source pixels that fall under each thumbnail pixel. Images already
+// small enough are copied unscaled. Transparent areas end up white, since
+// thumbnails are encoded as JPEG.
+func Thumbnail(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	dstW, dstH := srcW, srcH
+	if srcW > size || srcH > size {
+		if srcW >= srcH {
+			dstW, dstH = size, max(1, srcH*size/srcW)
+		} else {
+			dstW, dstH = max(1, srcW*size/srcH), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*srcH/dstH)
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := max(x0+1, bounds.Min.X+(x+1)*srcW/dstW)
+
+			var r, g, b, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					// Blend onto white.
+					r += uint64(cr + (0xffff - ca))
+					g += uint64(cg + (0xffff - ca))
+					b += uint64(cb + (0xffff - ca))
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: 0xffff,
+			})
+		}
+	}
+	return dst
+}
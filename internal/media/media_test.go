@@ -0,0 +1,226 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+// withExif splices an APP1 EXIF segment in after the JPEG SOI marker.
+func withExif(jpg []byte) []byte {
+	payload := append([]byte("Exif\x00\x00"), []byte("GPS 51.5074 N 0.1278 W")...)
+	length := len(payload) + 2
+	segment := append([]byte{0xff, 0xe1, byte(length >> 8), byte(length)}, payload...)
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, segment...)
+	return append(out, jpg[2:]...)
+}
+
+// withOrientation splices in an EXIF segment holding only an orientation
+// tag, in big-endian TIFF form.
+func withOrientation(jpg []byte, orientation byte) []byte {
+	payload := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08")
+	payload = append(payload, 0, 1)                             // One entry.
+	payload = append(payload, 0x01, 0x12, 0, 3, 0, 0, 0, 1)     // Orientation, one SHORT.
+	payload = append(payload, 0, orientation, 0, 0, 0, 0, 0, 0) // Value, then no next IFD.
+	length := len(payload) + 2
+	segment := append([]byte{0xff, 0xe1, byte(length >> 8), byte(length)}, payload...)
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, segment...)
+	return append(out, jpg[2:]...)
+}
+
+// framedGIF is a GIF header with frames w by h image descriptors. The frames
+// hold no image data, so it only gets as far as the size checks.
+func framedGIF(w, h, frames int) []byte {
+	data := []byte("GIF89a")
+	data = append(data, byte(w), byte(w>>8), byte(h), byte(h>>8), 0, 0, 0)
+	for i := 0; i < frames; i++ {
+		data = append(data, 0x2c, 0, 0, 0, 0, byte(w), byte(w>>8), byte(h), byte(h>>8), 0, 2, 0)
+	}
+	return append(data, 0x3b)
+}
+
+func TestProcess(t *testing.T) {
+	var pngBuf bytes.Buffer
+	if err := png.Encode(&pngBuf, testImage(640, 480)); err != nil {
+		t.Fatal(err)
+	}
+	var jpgBuf bytes.Buffer
+	if err := jpeg.Encode(&jpgBuf, testImage(100, 400), nil); err != nil {
+		t.Fatal(err)
+	}
+	jpgWithExif := withExif(jpgBuf.Bytes())
+
+	tests := []struct {
+		name       string
+		data       []byte
+		wantType   string
+		wantW      int
+		wantH      int
+		wantThumbW int
+		wantThumbH int
+		wantErr    error
+		wantAnyErr bool
+	}{
+		{
+			name:       "PNG",
+			data:       pngBuf.Bytes(),
+			wantType:   "image/png",
+			wantW:      640,
+			wantH:      480,
+			wantThumbW: 320,
+			wantThumbH: 240,
+		},
+		{
+			name:       "JPEG with EXIF",
+			data:       jpgWithExif,
+			wantType:   "image/jpeg",
+			wantW:      100,
+			wantH:      400,
+			wantThumbW: 80,
+			wantThumbH: 320,
+		},
+		{
+			name:       "JPEG taken on its side",
+			data:       withOrientation(jpgBuf.Bytes(), 6),
+			wantType:   "image/jpeg",
+			wantW:      400,
+			wantH:      100,
+			wantThumbW: 320,
+			wantThumbH: 80,
+		},
+		{
+			name:    "Not an image",
+			data:    []byte("<html><body>hi</body></html>"),
+			wantErr: ErrUnsupportedType,
+		},
+		{
+			name:    "Too many GIF frames",
+			data:    framedGIF(4000, 4000, 3),
+			wantErr: ErrTooLarge,
+		},
+		{
+			name:       "Truncated image",
+			data:       pngBuf.Bytes()[:100],
+			wantAnyErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Process(tt.data)
+			if tt.wantErr != nil || tt.wantAnyErr {
+				if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+					t.Fatalf("Process() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			if got.ContentType != tt.wantType || got.Width != tt.wantW || got.Height != tt.wantH {
+				t.Errorf("Process() = %s %dx%d, want %s %dx%d", got.ContentType, got.Width, got.Height, tt.wantType, tt.wantW, tt.wantH)
+			}
+			if bytes.Contains(got.Data, []byte("Exif")) || bytes.Contains(got.Data, []byte("GPS")) {
+				t.Errorf("Process() kept EXIF metadata")
+			}
+			thumb, err := jpeg.Decode(bytes.NewReader(got.Thumbnail))
+			if err != nil {
+				t.Fatalf("thumbnail isn't a JPEG: %v", err)
+			}
+			if b := thumb.Bounds(); b.Dx() != tt.wantThumbW || b.Dy() != tt.wantThumbH {
+				t.Errorf("thumbnail = %dx%d, want %dx%d", b.Dx(), b.Dy(), tt.wantThumbW, tt.wantThumbH)
+			}
+		})
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	root := t.TempDir()
+	storage, err := NewLocalStorage(root, "/media/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := storage.Put(ctx, "a/b.png", bytes.NewReader([]byte("data"))); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(root, "a", "b.png"))
+	if err != nil || string(got) != "data" {
+		t.Fatalf("stored file = %q, %v", got, err)
+	}
+	f, err := storage.Open(ctx, "a/b.png")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	got, _ = io.ReadAll(f)
+	f.Close()
+	if string(got) != "data" {
+		t.Errorf("Open() contents = %q", got)
+	}
+	if url := storage.URL("a/b.png"); url != "/media/a/b.png" {
+		t.Errorf("URL() = %v", url)
+	}
+
+	if err := storage.Put(ctx, "../escape", bytes.NewReader(nil)); err == nil {
+		t.Error("Put() allowed a key outside the root")
+	}
+
+	if err := storage.Delete(ctx, "a/b.png"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := storage.Delete(ctx, "a/b.png"); err != nil {
+		t.Errorf("Delete() of a missing file error = %v", err)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// Red on the left, blue on the right.
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, red)
+	img.Set(1, 0, blue)
+
+	tests := []struct {
+		orientation int
+		wantW       int
+		wantH       int
+		first       color.RGBA
+	}{
+		{1, 2, 1, red},
+		{3, 2, 1, blue},
+		{6, 1, 2, red},
+		{8, 1, 2, blue},
+	}
+	for _, tt := range tests {
+		got := orient(img, tt.orientation)
+		if b := got.Bounds(); b.Dx() != tt.wantW || b.Dy() != tt.wantH {
+			t.Errorf("orient(%d) = %dx%d, want %dx%d", tt.orientation, b.Dx(), b.Dy(), tt.wantW, tt.wantH)
+			continue
+		}
+		if c := color.RGBAModel.Convert(got.At(0, 0)); c != tt.first {
+			t.Errorf("orient(%d) top left = %v, want %v", tt.orientation, c, tt.first)
+		}
+	}
+}
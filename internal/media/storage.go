@@ -0,0 +1,97 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Storage holds uploaded files. Keys are slash-separated paths chosen by the
+// caller.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
+
+// LocalStorage keeps files in a directory on disk and hands out URLs under
+// baseURL, which the server is expected to serve that directory from.
+type LocalStorage struct {
+	root    string
+	baseURL string
+}
+
+// NewLocalStorage -
+func NewLocalStorage(root, baseURL string) (*LocalStorage, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{root: root, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// Put writes the file to a temporary name first so readers never see a
+// partial file.
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader) error {
+	dest, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// Open -
+func (s *LocalStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	dest, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(dest)
+}
+
+// Delete -
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	dest, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(dest)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// URL -
+func (s *LocalStorage) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || clean != "/"+key {
+		return "", errors.New("invalid storage key")
+	}
+	return filepath.Join(s.root, filepath.FromSlash(clean)), nil
+}
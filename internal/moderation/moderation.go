@@ -0,0 +1,123 @@
+package moderation
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Action is what a filter wants done with a chirp. Actions are ordered by
+// severity, so the strictest one wins when several filters match.
+type Action int
+
+const (
+	// ActionAllow -
+	ActionAllow Action = iota
+	// ActionCensor -
+	ActionCensor
+	// ActionFlag -
+	ActionFlag
+	// ActionReject -
+	ActionReject
+)
+
+// ErrRejected is returned by Pipeline.Run when a filter rejects a body.
+var ErrRejected = errors.New("chirp rejected by moderation")
+
+func (a Action) String() string {
+	switch a {
+	case ActionAllow:
+		return "allow"
+	case ActionCensor:
+		return "censor"
+	case ActionFlag:
+		return "flag"
+	case ActionReject:
+		return "reject"
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+// MarshalText -
+func (a Action) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText -
+func (a *Action) UnmarshalText(text []byte) error {
+	action, err := ParseAction(string(text))
+	if err != nil {
+		return err
+	}
+	*a = action
+	return nil
+}
+
+// ParseAction -
+func ParseAction(s string) (Action, error) {
+	switch s {
+	case "allow":
+		return ActionAllow, nil
+	case "censor":
+		return ActionCensor, nil
+	case "flag":
+		return ActionFlag, nil
+	case "reject":
+		return ActionReject, nil
+	}
+	return ActionAllow, fmt.Errorf("unknown moderation action %q", s)
+}
+
+// Verdict is one filter's decision about a body.
+type Verdict struct {
+	Filter  string   `json:"filter"`
+	Action  Action   `json:"action"`
+	Matches []string `json:"matches"`
+}
+
+// Filter inspects a body and returns it, possibly rewritten, along with its
+// verdict. Filters that censor return the censored body.
+type Filter interface {
+	Name() string
+	Apply(body string) (string, Verdict)
+}
+
+// Outcome is the combined result of running every filter in a pipeline.
+type Outcome struct {
+	Action   Action
+	Body     string
+	Verdicts []Verdict
+}
+
+// Pipeline runs filters in order, feeding each one the body the previous
+// filter returned.
+type Pipeline struct {
+	filters []Filter
+}
+
+// NewPipeline -
+func NewPipeline(filters ...Filter) *Pipeline {
+	return &Pipeline{filters: filters}
+}
+
+// Run applies the pipeline to body. It stops at the first filter that
+// rejects and returns ErrRejected alongside the outcome so callers can still
+// see why.
+func (p *Pipeline) Run(body string) (Outcome, error) {
+	outcome := Outcome{Action: ActionAllow, Body: body, Verdicts: []Verdict{}}
+	for _, filter := range p.filters {
+		cleaned, verdict := filter.Apply(outcome.Body)
+		if verdict.Action == ActionAllow {
+			continue
+		}
+		verdict.Filter = filter.Name()
+		outcome.Verdicts = append(outcome.Verdicts, verdict)
+		if verdict.Action > outcome.Action {
+			outcome.Action = verdict.Action
+		}
+		if verdict.Action == ActionReject {
+			return outcome, ErrRejected
+		}
+		outcome.Body = cleaned
+	}
+	return outcome, nil
+}
@@ -0,0 +1,131 @@
+package moderation
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWordFilterApply(t *testing.T) {
+	filter := NewWordFilter([]Word{
+		{Word: "kerfuffle", Action: ActionCensor},
+		{Word: "sharbert", Action: ActionCensor},
+		{Word: "fornax", Action: ActionFlag},
+		{Word: "Straße", Action: ActionCensor},
+	})
+
+	tests := []struct {
+		name        string
+		body        string
+		wantBody    string
+		wantAction  Action
+		wantMatches []string
+	}{
+		{
+			name:        "Clean body",
+			body:        "I had something interesting for breakfast",
+			wantBody:    "I had something interesting for breakfast",
+			wantAction:  ActionAllow,
+			wantMatches: []string{},
+		},
+		{
+			name:        "Trailing punctuation",
+			body:        "What a kerfuffle! Sharbert.",
+			wantBody:    "What a ****! ****.",
+			wantAction:  ActionCensor,
+			wantMatches: []string{"kerfuffle", "Sharbert"},
+		},
+		{
+			name:        "Mixed case",
+			body:        "KerFUFFLE",
+			wantBody:    "****",
+			wantAction:  ActionCensor,
+			wantMatches: []string{"KerFUFFLE"},
+		},
+		{
+			name:        "Part of a longer word",
+			body:        "kerfuffles sharberts",
+			wantBody:    "kerfuffles sharberts",
+			wantAction:  ActionAllow,
+			wantMatches: []string{},
+		},
+		{
+			name:        "Non-ASCII word",
+			body:        "on the STRASSE or the STRAßE",
+			wantBody:    "on the STRASSE or the ****",
+			wantAction:  ActionCensor,
+			wantMatches: []string{"STRAßE"},
+		},
+		{
+			name:        "Flagged word is kept",
+			body:        "fornax, kerfuffle",
+			wantBody:    "fornax, ****",
+			wantAction:  ActionFlag,
+			wantMatches: []string{"fornax", "kerfuffle"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotBody, verdict := filter.Apply(tt.body)
+			if gotBody != tt.wantBody {
+				t.Errorf("Apply() body = %q, want %q", gotBody, tt.wantBody)
+			}
+			if verdict.Action != tt.wantAction {
+				t.Errorf("Apply() action = %v, want %v", verdict.Action, tt.wantAction)
+			}
+			if !reflect.DeepEqual(verdict.Matches, tt.wantMatches) {
+				t.Errorf("Apply() matches = %v, want %v", verdict.Matches, tt.wantMatches)
+			}
+		})
+	}
+}
+
+func TestPipelineRun(t *testing.T) {
+	pipeline := NewPipeline(
+		NewWordFilter([]Word{{Word: "kerfuffle", Action: ActionCensor}}),
+		NewWordFilter([]Word{{Word: "spam", Action: ActionReject}}),
+	)
+
+	outcome, err := pipeline.Run("a kerfuffle")
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if outcome.Body != "a ****" || outcome.Action != ActionCensor || len(outcome.Verdicts) != 1 {
+		t.Errorf("Run() = %+v", outcome)
+	}
+
+	outcome, err = pipeline.Run("kerfuffle spam")
+	if !errors.Is(err, ErrRejected) {
+		t.Fatalf("Run() error = %v, want ErrRejected", err)
+	}
+	if outcome.Action != ActionReject || len(outcome.Verdicts) != 2 {
+		t.Errorf("Run() = %+v", outcome)
+	}
+}
+
+func TestReadWords(t *testing.T) {
+	input := `# default list
+kerfuffle
+fornax flag
+
+spam reject
+`
+	got, err := ReadWords(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadWords() error = %v", err)
+	}
+	want := []Word{
+		{Word: "kerfuffle", Action: ActionCensor},
+		{Word: "fornax", Action: ActionFlag},
+		{Word: "spam", Action: ActionReject},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadWords() = %v, want %v", got, want)
+	}
+
+	if _, err := ReadWords(strings.NewReader("spam delete")); err == nil {
+		t.Error("ReadWords() expected error for unknown action")
+	}
+}
@@ -0,0 +1,158 @@
+package moderation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const censored = "****"
+
+// Word is an entry in a wordlist and the action to take when it appears.
+type Word struct {
+	Word   string
+	Action Action
+}
+
+// WordFilter matches whole words against a wordlist. Words are found on
+// Unicode letter and digit boundaries and compared case-insensitively, so
+// surrounding punctuation doesn't hide them. The list can be swapped while
+// the server is running.
+type WordFilter struct {
+	mu    sync.RWMutex
+	words map[string]Action
+}
+
+// NewWordFilter -
+func NewWordFilter(words []Word) *WordFilter {
+	f := &WordFilter{}
+	f.SetWords(words)
+	return f
+}
+
+// SetWords replaces the wordlist.
+func (f *WordFilter) SetWords(words []Word) {
+	m := make(map[string]Action, len(words))
+	for _, w := range words {
+		key := foldWord(w.Word)
+		if key == "" {
+			continue
+		}
+		if w.Action > m[key] {
+			m[key] = w.Action
+		}
+	}
+	f.mu.Lock()
+	f.words = m
+	f.mu.Unlock()
+}
+
+// Name -
+func (f *WordFilter) Name() string {
+	return "wordlist"
+}
+
+// Apply -
+func (f *WordFilter) Apply(body string) (string, Verdict) {
+	f.mu.RLock()
+	words := f.words
+	f.mu.RUnlock()
+
+	verdict := Verdict{Action: ActionAllow, Matches: []string{}}
+	var out strings.Builder
+	runes := []rune(body)
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			out.WriteRune(runes[i])
+			i++
+			continue
+		}
+		end := i
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+		word := string(runes[i:end])
+		i = end
+
+		action, ok := words[foldWord(word)]
+		if !ok {
+			out.WriteString(word)
+			continue
+		}
+		verdict.Matches = append(verdict.Matches, word)
+		if action > verdict.Action {
+			verdict.Action = action
+		}
+		if action == ActionCensor {
+			out.WriteString(censored)
+		} else {
+			out.WriteString(word)
+		}
+	}
+	return out.String(), verdict
+}
+
+// LoadWordsFile reads a wordlist file: one word per line, optionally
+// followed by an action (censor, flag or reject; censor if omitted). Blank
+// lines and lines starting with # are ignored.
+func LoadWordsFile(path string) ([]Word, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadWords(f)
+}
+
+// ReadWords parses a wordlist in the format read by LoadWordsFile.
+func ReadWords(r io.Reader) ([]Word, error) {
+	words := []Word{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		word := Word{Word: fields[0], Action: ActionCensor}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("line %d: expected a word and an optional action", line)
+		}
+		if len(fields) == 2 {
+			action, err := ParseAction(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			word.Action = action
+		}
+		words = append(words, word)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+// foldWord maps every rune to the smallest rune in its case-folding orbit so
+// that words differing only in case compare equal as map keys.
+func foldWord(s string) string {
+	return strings.Map(func(r rune) rune {
+		folded := r
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			if f < folded {
+				folded = f
+			}
+		}
+		return folded
+	}, s)
+}
@@ -0,0 +1,93 @@
+package oauth
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/mrcordova/chirpy/internal/auth"
+)
+
+// scopeDescriptions say what each scope lets an app do, for the consent
+// page.
+var scopeDescriptions = map[string]string{
+	auth.ScopeChirpsRead:   "Read your timeline",
+	auth.ScopeChirpsWrite:  "Post, edit, delete, like and rechirp chirps as you",
+	auth.ScopeFollowsWrite: "Follow and unfollow people as you",
+	auth.ScopeProfileWrite: "Change your profile",
+}
+
+var consentTemplate = template.Must(template.New("consent").Funcs(template.FuncMap{
+	"describe": func(scope string) string {
+		if d, ok := scopeDescriptions[scope]; ok {
+			return d
+		}
+		return scope
+	},
+	"join": func(scopes []string) string {
+		return strings.Join(scopes, " ")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <title>Authorize {{.Request.Client.Name}} - Chirpy</title>
+  </head>
+  <body>
+    <h1>{{.Request.Client.Name}} wants to use your Chirpy account</h1>
+    <p>It will be able to:</p>
+    <ul>
+      {{range .Request.Scopes}}<li>{{describe .}}</li>
+      {{end}}
+    </ul>
+    {{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+    <form method="post" action="/oauth/authorize">
+      <input type="hidden" name="response_type" value="code">
+      <input type="hidden" name="client_id" value="{{.Request.Client.ID}}">
+      {{if .Request.RequestedRedirectURI}}<input type="hidden" name="redirect_uri" value="{{.Request.RequestedRedirectURI}}">{{end}}
+      <input type="hidden" name="scope" value="{{join .Request.Scopes}}">
+      <input type="hidden" name="state" value="{{.Request.State}}">
+      <input type="hidden" name="code_challenge" value="{{.Request.CodeChallenge}}">
+      <input type="hidden" name="code_challenge_method" value="{{.Request.CodeChallengeMethod}}">
+      <p><label>Email <input type="email" name="email" autocomplete="username" required></label></p>
+      <p><label>Password <input type="password" name="password" autocomplete="current-password" required></label></p>
+      <p><label>Two-factor or recovery code, if you use 2FA <input type="text" name="otp" autocomplete="one-time-code"></label></p>
+      <button type="submit" name="decision" value="approve">Allow</button>
+      <button type="submit" name="decision" value="deny" formnovalidate>Deny</button>
+    </form>
+  </body>
+</html>
+`))
+
+var errorTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <title>Authorization failed - Chirpy</title>
+  </head>
+  <body>
+    <h1>Authorization failed</h1>
+    <p>{{.}}</p>
+  </body>
+</html>
+`))
+
+func renderConsent(w http.ResponseWriter, status int, req authRequest, errMsg string) {
+	render(w, status, consentTemplate, struct {
+		Request authRequest
+		Error   string
+	}{req, errMsg})
+}
+
+func renderError(w http.ResponseWriter, status int, msg string) {
+	render(w, status, errorTemplate, msg)
+}
+
+func render(w http.ResponseWriter, status int, tmpl *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Error rendering %s page: %s", tmpl.Name(), err)
+	}
+}
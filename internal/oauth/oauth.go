@@ -0,0 +1,439 @@
+package oauth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/auth"
+)
+
+// CodeDuration is how long an authorization code can be exchanged for.
+const CodeDuration = 5 * time.Minute
+
+// ErrNotFound is returned by a Store for unknown clients and codes.
+var ErrNotFound = errors.New("not found")
+
+// ErrInvalidGrant is returned by a Provider when a refresh token is
+// unknown, expired, revoked or belongs to another client.
+var ErrInvalidGrant = errors.New("invalid grant")
+
+// Client is an app registered to act for Chirpy users.
+type Client struct {
+	ID   string
+	Name string
+	// SecretHash is the auth.HashToken of the client secret. Public clients,
+	// like mobile and single-page apps, have none and rely on PKCE alone.
+	SecretHash   string
+	RedirectURIs []string
+	// Scopes are the most the client may ask for.
+	Scopes []string
+}
+
+// Code is what an authorization code stands for.
+type Code struct {
+	ClientID string
+	UserID   uuid.UUID
+	// RedirectURI is the redirect_uri the authorization request sent, or
+	// empty if it left it out. Only if it was sent must the token request
+	// repeat it.
+	RedirectURI   string
+	Scopes        []string
+	CodeChallenge string
+	ExpiresAt     time.Time
+}
+
+// Store keeps clients and authorization codes. Codes are stored by the
+// auth.HashToken of the code.
+type Store interface {
+	GetClient(ctx context.Context, id string) (Client, error)
+	CreateCode(ctx context.Context, codeHash string, code Code) error
+	// UseCode marks a code used and returns it, or ErrNotFound if it's
+	// unknown or was already used.
+	UseCode(ctx context.Context, codeHash string) (Code, error)
+}
+
+// Credentials are what the user types on the consent page.
+type Credentials struct {
+	Email    string
+	Password string
+	// OTP is a 2FA or recovery code, for accounts that need one.
+	OTP string
+}
+
+// LoginError is a failed sign-in on the consent page. Its message is shown
+// to the user.
+type LoginError struct {
+	Message string
+}
+
+func (e *LoginError) Error() string {
+	return e.Message
+}
+
+// Tokens are what the token endpoint hands back.
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+	Scopes       []string
+}
+
+// Provider is the app the server issues tokens for.
+type Provider interface {
+	// Authenticate signs in the user at the consent page. Errors that
+	// should be shown to them are *LoginError.
+	Authenticate(r *http.Request, creds Credentials) (uuid.UUID, error)
+	// IssueTokens starts a session for userID on behalf of clientID.
+	IssueTokens(r *http.Request, userID uuid.UUID, clientID string, scopes []string) (Tokens, error)
+	// RefreshTokens rotates a refresh token issued to clientID. It returns
+	// an error wrapping ErrInvalidGrant if the token can't be used.
+	RefreshTokens(r *http.Request, refreshToken, clientID string) (Tokens, error)
+}
+
+// Server is an OAuth 2.0 authorization server supporting the
+// authorization code grant with PKCE and the refresh token grant.
+type Server struct {
+	store    Store
+	provider Provider
+	now      func() time.Time
+}
+
+func NewServer(store Store, provider Provider) *Server {
+	return &Server{
+		store:    store,
+		provider: provider,
+		now:      func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// authRequest is a validated authorization request.
+type authRequest struct {
+	Client Client
+	// RedirectURI is where the user is sent back to. RequestedRedirectURI
+	// is what the client sent, which may be empty.
+	RedirectURI          string
+	RequestedRedirectURI string
+	Scopes               []string
+	State                string
+	CodeChallenge        string
+	CodeChallengeMethod  string
+}
+
+// redirectError is a problem with an authorization request that is sent
+// back to the client's redirect URI, as opposed to shown to the user.
+type redirectError struct {
+	code        string
+	description string
+}
+
+func (e *redirectError) Error() string {
+	return e.code + ": " + e.description
+}
+
+// requestError is a problem with an authorization request that can't be
+// sent back to the client, because the client or redirect URI is bad, so
+// it's shown to the user instead.
+type requestError struct {
+	msg string
+}
+
+func (e *requestError) Error() string {
+	return e.msg
+}
+
+// parseAuthRequest checks the query or form of an authorization request.
+// Until the client and redirect URI check out, problems are *requestError;
+// after that they are *redirectError.
+func (s *Server) parseAuthRequest(r *http.Request) (authRequest, error) {
+	req := authRequest{
+		State:               r.FormValue("state"),
+		CodeChallenge:       r.FormValue("code_challenge"),
+		CodeChallengeMethod: r.FormValue("code_challenge_method"),
+	}
+
+	client, err := s.store.GetClient(r.Context(), r.FormValue("client_id"))
+	if errors.Is(err, ErrNotFound) {
+		return req, &requestError{"Unknown client"}
+	}
+	if err != nil {
+		return req, err
+	}
+	req.Client = client
+
+	// Redirect URIs must match exactly. One can be left out only if the
+	// client has just one, since otherwise we wouldn't know where to go.
+	req.RequestedRedirectURI = r.FormValue("redirect_uri")
+	req.RedirectURI = req.RequestedRedirectURI
+	if req.RedirectURI == "" && len(client.RedirectURIs) == 1 {
+		req.RedirectURI = client.RedirectURIs[0]
+	}
+	if !auth.HasScope(client.RedirectURIs, req.RedirectURI) {
+		return req, &requestError{"Redirect URI isn't registered for this client"}
+	}
+
+	if r.FormValue("response_type") != "code" {
+		return req, &redirectError{"unsupported_response_type", "Only the code response type is supported"}
+	}
+	req.Scopes = client.Scopes
+	if scope := r.FormValue("scope"); scope != "" {
+		req.Scopes, err = auth.ParseScopes(scope, client.Scopes)
+		if err != nil {
+			return req, &redirectError{"invalid_scope", err.Error()}
+		}
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return req, &redirectError{"invalid_request", "PKCE with code_challenge_method S256 is required"}
+	}
+	return req, nil
+}
+
+// HandleAuthorize serves the consent page on GET and handles the user's
+// decision on POST.
+func (s *Server) HandleAuthorize(w http.ResponseWriter, r *http.Request) {
+	// The consent page must not be framed, or another site could trick
+	// users into approving.
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+
+	req, err := s.parseAuthRequest(r)
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		renderError(w, http.StatusBadRequest, reqErr.msg)
+		return
+	}
+	var redirErr *redirectError
+	if errors.As(err, &redirErr) {
+		redirect(w, r, req.RedirectURI, url.Values{
+			"error":             {redirErr.code},
+			"error_description": {redirErr.description},
+			"state":             {req.State},
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading authorization request: %s", err)
+		renderError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		renderConsent(w, http.StatusOK, req, "")
+		return
+	}
+
+	if r.FormValue("decision") != "approve" {
+		redirect(w, r, req.RedirectURI, url.Values{
+			"error":             {"access_denied"},
+			"error_description": {"The user denied the request"},
+			"state":             {req.State},
+		})
+		return
+	}
+
+	userID, err := s.provider.Authenticate(r, Credentials{
+		Email:    r.FormValue("email"),
+		Password: r.FormValue("password"),
+		OTP:      r.FormValue("otp"),
+	})
+	var loginErr *LoginError
+	if errors.As(err, &loginErr) {
+		renderConsent(w, http.StatusUnauthorized, req, loginErr.Message)
+		return
+	}
+	if err != nil {
+		log.Printf("Error signing in on consent page: %s", err)
+		renderError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	code, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("Error creating authorization code: %s", err)
+		renderError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	err = s.store.CreateCode(r.Context(), auth.HashToken(code), Code{
+		ClientID:      req.Client.ID,
+		UserID:        userID,
+		RedirectURI:   req.RequestedRedirectURI,
+		Scopes:        req.Scopes,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     s.now().Add(CodeDuration),
+	})
+	if err != nil {
+		log.Printf("Error saving authorization code: %s", err)
+		renderError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	redirect(w, r, req.RedirectURI, url.Values{
+		"code":  {code},
+		"state": {req.State},
+	})
+}
+
+// redirect sends the user agent back to the client with params added to
+// the redirect URI's query.
+func redirect(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		renderError(w, http.StatusBadRequest, "Invalid redirect URI")
+		return
+	}
+	query := u.Query()
+	for key, values := range params {
+		if len(values) > 0 && values[0] != "" {
+			query.Set(key, values[0])
+		}
+	}
+	u.RawQuery = query.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// tokenError is an RFC 6749 section 5.2 error response.
+type tokenError struct {
+	status      int
+	code        string
+	description string
+}
+
+func (e *tokenError) Error() string {
+	return e.code + ": " + e.description
+}
+
+// HandleToken is the token endpoint. It exchanges authorization codes and
+// refresh tokens for new tokens.
+func (s *Server) HandleToken(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+		Scope        string `json:"scope"`
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	client, err := s.authenticateClient(r)
+	if err != nil {
+		writeTokenError(w, err)
+		return
+	}
+
+	var tokens Tokens
+	switch r.PostFormValue("grant_type") {
+	case "authorization_code":
+		tokens, err = s.exchangeCode(r, client)
+	case "refresh_token":
+		tokens, err = s.provider.RefreshTokens(r, r.PostFormValue("refresh_token"), client.ID)
+		if errors.Is(err, ErrInvalidGrant) {
+			err = &tokenError{http.StatusBadRequest, "invalid_grant", err.Error()}
+		}
+	default:
+		err = &tokenError{http.StatusBadRequest, "unsupported_grant_type", "Only authorization_code and refresh_token grants are supported"}
+	}
+	if err != nil {
+		writeTokenError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(tokens.ExpiresIn / time.Second),
+		RefreshToken: tokens.RefreshToken,
+		Scope:        strings.Join(tokens.Scopes, " "),
+	})
+}
+
+// authenticateClient identifies the client calling the token endpoint.
+// Confidential clients must send their secret, with HTTP Basic auth or in
+// the form.
+func (s *Server) authenticateClient(r *http.Request) (Client, error) {
+	clientID, secret, basic := r.BasicAuth()
+	if basic {
+		// RFC 6749 section 2.3.1 form-encodes both before Basic encoding.
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID = r.PostFormValue("client_id")
+		secret = r.PostFormValue("client_secret")
+	}
+
+	invalid := &tokenError{http.StatusUnauthorized, "invalid_client", "Client authentication failed"}
+	client, err := s.store.GetClient(r.Context(), clientID)
+	if errors.Is(err, ErrNotFound) {
+		return Client{}, invalid
+	}
+	if err != nil {
+		return Client{}, err
+	}
+	if client.SecretHash != "" {
+		if subtle.ConstantTimeCompare([]byte(auth.HashToken(secret)), []byte(client.SecretHash)) != 1 {
+			return Client{}, invalid
+		}
+	}
+	return client, nil
+}
+
+func (s *Server) exchangeCode(r *http.Request, client Client) (Tokens, error) {
+	invalid := func(description string) error {
+		return &tokenError{http.StatusBadRequest, "invalid_grant", description}
+	}
+
+	code, err := s.store.UseCode(r.Context(), auth.HashToken(r.PostFormValue("code")))
+	if errors.Is(err, ErrNotFound) {
+		return Tokens{}, invalid("Authorization code is invalid or was already used")
+	}
+	if err != nil {
+		return Tokens{}, err
+	}
+	if code.ClientID != client.ID {
+		return Tokens{}, invalid("Authorization code was issued to another client")
+	}
+	if s.now().After(code.ExpiresAt) {
+		return Tokens{}, invalid("Authorization code has expired")
+	}
+	// RFC 6749 section 4.1.3 only asks for redirect_uri here if the
+	// authorization request had it.
+	if code.RedirectURI != "" && code.RedirectURI != r.PostFormValue("redirect_uri") {
+		return Tokens{}, invalid("redirect_uri doesn't match the authorization request")
+	}
+	if !VerifyPKCE(r.PostFormValue("code_verifier"), code.CodeChallenge) {
+		return Tokens{}, invalid("code_verifier doesn't match the code challenge")
+	}
+
+	return s.provider.IssueTokens(r, code.UserID, client.ID, code.Scopes)
+}
+
+func writeTokenError(w http.ResponseWriter, err error) {
+	type errorResponse struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description,omitempty"`
+	}
+
+	var tokenErr *tokenError
+	if !errors.As(err, &tokenErr) {
+		log.Printf("Error at token endpoint: %s", err)
+		tokenErr = &tokenError{http.StatusInternalServerError, "server_error", ""}
+	}
+	if tokenErr.status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(tokenErr.status)
+	json.NewEncoder(w).Encode(errorResponse{
+		Error:            tokenErr.code,
+		ErrorDescription: tokenErr.description,
+	})
+}
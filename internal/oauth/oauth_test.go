@@ -0,0 +1,380 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/auth"
+)
+
+const testVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+func TestVerifyPKCE(t *testing.T) {
+	// From RFC 7636 appendix B.
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	if got := S256Challenge(testVerifier); got != challenge {
+		t.Fatalf("S256Challenge() = %q, want %q", got, challenge)
+	}
+
+	tests := []struct {
+		name     string
+		verifier string
+		want     bool
+	}{
+		{"Matches", testVerifier, true},
+		{"Wrong verifier", strings.Repeat("a", 43), false},
+		{"Too short", testVerifier[:42], false},
+		{"Too long", strings.Repeat("a", 129), false},
+		{"Reserved character", testVerifier[:42] + "/", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyPKCE(tt.verifier, challenge); got != tt.want {
+				t.Errorf("VerifyPKCE() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type memoryStore struct {
+	mu      sync.Mutex
+	clients map[string]Client
+	codes   map[string]Code
+}
+
+func (m *memoryStore) GetClient(ctx context.Context, id string) (Client, error) {
+	client, ok := m.clients[id]
+	if !ok {
+		return Client{}, ErrNotFound
+	}
+	return client, nil
+}
+
+func (m *memoryStore) CreateCode(ctx context.Context, codeHash string, code Code) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.codes[codeHash] = code
+	return nil
+}
+
+func (m *memoryStore) UseCode(ctx context.Context, codeHash string) (Code, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	code, ok := m.codes[codeHash]
+	if !ok {
+		return Code{}, ErrNotFound
+	}
+	delete(m.codes, codeHash)
+	return code, nil
+}
+
+type fakeProvider struct {
+	userID  uuid.UUID
+	refresh map[string]string
+}
+
+func (p *fakeProvider) Authenticate(r *http.Request, creds Credentials) (uuid.UUID, error) {
+	if creds.Email != "walt@example.com" || creds.Password != "heisenberg" {
+		return uuid.Nil, &LoginError{"Incorrect email or password"}
+	}
+	return p.userID, nil
+}
+
+func (p *fakeProvider) IssueTokens(r *http.Request, userID uuid.UUID, clientID string, scopes []string) (Tokens, error) {
+	refresh := uuid.NewString()
+	p.refresh[refresh] = clientID
+	return Tokens{
+		AccessToken:  "access-" + userID.String(),
+		RefreshToken: refresh,
+		ExpiresIn:    time.Hour,
+		Scopes:       scopes,
+	}, nil
+}
+
+func (p *fakeProvider) RefreshTokens(r *http.Request, refreshToken, clientID string) (Tokens, error) {
+	owner, ok := p.refresh[refreshToken]
+	if !ok || owner != clientID {
+		return Tokens{}, fmt.Errorf("refresh token not found: %w", ErrInvalidGrant)
+	}
+	delete(p.refresh, refreshToken)
+	return p.IssueTokens(r, p.userID, clientID, []string{auth.ScopeChirpsRead})
+}
+
+const (
+	testRedirect = "https://app.example.com/callback"
+	testSecret   = "s3cret"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, *fakeProvider) {
+	t.Helper()
+	store := &memoryStore{
+		clients: map[string]Client{
+			"app": {
+				ID:           "app",
+				Name:         "Test App",
+				SecretHash:   auth.HashToken(testSecret),
+				RedirectURIs: []string{testRedirect},
+				Scopes:       []string{auth.ScopeChirpsRead, auth.ScopeChirpsWrite},
+			},
+		},
+		codes: map[string]Code{},
+	}
+	provider := &fakeProvider{userID: uuid.New(), refresh: map[string]string{}}
+	s := NewServer(store, provider)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /oauth/authorize", s.HandleAuthorize)
+	mux.HandleFunc("POST /oauth/authorize", s.HandleAuthorize)
+	mux.HandleFunc("POST /oauth/token", s.HandleToken)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, provider
+}
+
+func noRedirects(req *http.Request, via []*http.Request) error {
+	return http.ErrUseLastResponse
+}
+
+func authorizeParams() url.Values {
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"app"},
+		"redirect_uri":          {testRedirect},
+		"scope":                 {auth.ScopeChirpsRead},
+		"state":                 {"xyz"},
+		"code_challenge":        {S256Challenge(testVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+// authorize approves the request and returns the query the user agent is
+// redirected back to the client with.
+func authorize(t *testing.T, srv *httptest.Server, form url.Values) url.Values {
+	t.Helper()
+	client := &http.Client{CheckRedirect: noRedirects}
+	resp, err := client.PostForm(srv.URL+"/oauth/authorize", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status = %d, want %d", resp.StatusCode, http.StatusFound)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(location.String(), testRedirect) {
+		t.Fatalf("redirected to %s, want %s", location, testRedirect)
+	}
+	return location.Query()
+}
+
+func approvedForm() url.Values {
+	form := authorizeParams()
+	form.Set("decision", "approve")
+	form.Set("email", "walt@example.com")
+	form.Set("password", "heisenberg")
+	return form
+}
+
+func token(t *testing.T, srv *httptest.Server, form url.Values) (int, map[string]any) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("app", testSecret)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body := map[string]any{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, body
+}
+
+func codeExchange(code string) url.Values {
+	return url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {testRedirect},
+		"code_verifier": {testVerifier},
+	}
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	srv, provider := newTestServer(t)
+
+	resp, err := http.Get(srv.URL + "/oauth/authorize?" + authorizeParams().Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("consent page status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if resp.Header.Get("X-Frame-Options") != "DENY" {
+		t.Error("consent page can be framed")
+	}
+
+	query := authorize(t, srv, approvedForm())
+	if query.Get("state") != "xyz" {
+		t.Errorf("state = %q, want %q", query.Get("state"), "xyz")
+	}
+	code := query.Get("code")
+	if code == "" {
+		t.Fatalf("no code in redirect: %v", query)
+	}
+
+	status, body := token(t, srv, codeExchange(code))
+	if status != http.StatusOK {
+		t.Fatalf("token status = %d, want %d: %v", status, http.StatusOK, body)
+	}
+	if body["access_token"] != "access-"+provider.userID.String() || body["token_type"] != "Bearer" || body["scope"] != auth.ScopeChirpsRead {
+		t.Errorf("unexpected token response: %v", body)
+	}
+
+	// Codes can only be used once.
+	status, body = token(t, srv, codeExchange(code))
+	if status != http.StatusBadRequest || body["error"] != "invalid_grant" {
+		t.Errorf("reused code: status = %d, body = %v", status, body)
+	}
+
+	// A client with one redirect URI can leave it out of both requests.
+	form := approvedForm()
+	form.Del("redirect_uri")
+	query = authorize(t, srv, form)
+	exchange := codeExchange(query.Get("code"))
+	exchange.Del("redirect_uri")
+	status, body = token(t, srv, exchange)
+	if status != http.StatusOK {
+		t.Errorf("token without redirect_uri: status = %d, body = %v", status, body)
+	}
+}
+
+func TestRefreshGrant(t *testing.T) {
+	srv, _ := newTestServer(t)
+	code := authorize(t, srv, approvedForm()).Get("code")
+	_, body := token(t, srv, codeExchange(code))
+	refresh := body["refresh_token"].(string)
+
+	status, body := token(t, srv, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refresh}})
+	if status != http.StatusOK || body["refresh_token"] == refresh {
+		t.Fatalf("refresh: status = %d, body = %v", status, body)
+	}
+
+	status, body = token(t, srv, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refresh}})
+	if status != http.StatusBadRequest || body["error"] != "invalid_grant" {
+		t.Errorf("reused refresh token: status = %d, body = %v", status, body)
+	}
+}
+
+func TestTokenErrors(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		modify func(url.Values)
+		status int
+		err    string
+	}{
+		{"Wrong verifier", func(f url.Values) { f.Set("code_verifier", strings.Repeat("a", 43)) }, http.StatusBadRequest, "invalid_grant"},
+		{"Wrong redirect URI", func(f url.Values) { f.Set("redirect_uri", "https://app.example.com/other") }, http.StatusBadRequest, "invalid_grant"},
+		{"Unknown grant", func(f url.Values) { f.Set("grant_type", "password") }, http.StatusBadRequest, "unsupported_grant_type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := codeExchange(authorize(t, srv, approvedForm()).Get("code"))
+			tt.modify(form)
+			status, body := token(t, srv, form)
+			if status != tt.status || body["error"] != tt.err {
+				t.Errorf("status = %d, body = %v, want %d %s", status, body, tt.status, tt.err)
+			}
+		})
+	}
+
+	t.Run("Wrong secret", func(t *testing.T) {
+		form := codeExchange(authorize(t, srv, approvedForm()).Get("code"))
+		form.Set("client_id", "app")
+		form.Set("client_secret", "wrong")
+		resp, err := http.PostForm(srv.URL+"/oauth/token", form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+		}
+	})
+}
+
+func TestAuthorizeErrors(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	t.Run("Deny", func(t *testing.T) {
+		form := authorizeParams()
+		form.Set("decision", "deny")
+		query := authorize(t, srv, form)
+		if query.Get("error") != "access_denied" || query.Get("code") != "" {
+			t.Errorf("unexpected redirect: %v", query)
+		}
+	})
+
+	t.Run("Scope not allowed", func(t *testing.T) {
+		form := authorizeParams()
+		form.Set("scope", auth.ScopeAccount)
+		query := authorize(t, srv, form)
+		if query.Get("error") != "invalid_scope" {
+			t.Errorf("unexpected redirect: %v", query)
+		}
+	})
+
+	t.Run("No PKCE", func(t *testing.T) {
+		form := authorizeParams()
+		form.Del("code_challenge")
+		query := authorize(t, srv, form)
+		if query.Get("error") != "invalid_request" {
+			t.Errorf("unexpected redirect: %v", query)
+		}
+	})
+
+	t.Run("Wrong password", func(t *testing.T) {
+		form := approvedForm()
+		form.Set("password", "wrong")
+		resp, err := http.PostForm(srv.URL+"/oauth/authorize", form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+		}
+	})
+
+	// Unregistered redirect URIs must not be redirected to, or the server
+	// would be an open redirector.
+	t.Run("Unregistered redirect URI", func(t *testing.T) {
+		form := authorizeParams()
+		form.Set("redirect_uri", "https://evil.example.com/")
+		client := &http.Client{CheckRedirect: noRedirects}
+		resp, err := client.Get(srv.URL + "/oauth/authorize?" + form.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+		}
+	})
+}
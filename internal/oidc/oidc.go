@@ -0,0 +1,327 @@
+// Package oidc signs users in with an external OpenID Connect provider
+// using the authorization code flow.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mrcordova/chirpy/internal/auth"
+)
+
+const (
+	// keyRefreshInterval limits how often an unknown kid makes us fetch the
+	// provider's keys again, so junk tokens can't make us hammer it.
+	keyRefreshInterval = time.Minute
+	// clockSkew is how far our clock and the provider's may disagree.
+	clockSkew = time.Minute
+	// maxResponseBytes caps what we read from the provider.
+	maxResponseBytes = 1 << 20
+)
+
+// ErrInvalidIDToken is wrapped by every ID token verification failure.
+var ErrInvalidIDToken = errors.New("invalid ID token")
+
+// Config describes how Chirpy is registered with the provider.
+type Config struct {
+	// Issuer is the provider's issuer URL. Discovery fetches
+	// Issuer + "/.well-known/openid-configuration".
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes default to openid, email and profile.
+	Scopes     []string
+	HTTPClient *http.Client
+}
+
+// Provider is a discovered OpenID Connect provider.
+type Provider struct {
+	config   Config
+	authURL  string
+	tokenURL string
+	jwksURL  string
+	now      func() time.Time
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+// IDToken holds the claims Chirpy uses from a verified ID token.
+type IDToken struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Discover reads the provider's metadata and signing keys.
+func Discover(ctx context.Context, config Config) (*Provider, error) {
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+
+	var metadata struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	p := &Provider{config: config, now: time.Now}
+	if err := p.getJSON(ctx, config.Issuer+"/.well-known/openid-configuration", &metadata); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	// The metadata must be about the issuer we asked for, or a provider
+	// could vouch for users of another one.
+	if strings.TrimSuffix(metadata.Issuer, "/") != config.Issuer {
+		return nil, fmt.Errorf("discovery: issuer is %q, want %q", metadata.Issuer, config.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("discovery: metadata is missing an endpoint")
+	}
+	p.config.Issuer = metadata.Issuer
+	p.authURL = metadata.AuthorizationEndpoint
+	p.tokenURL = metadata.TokenEndpoint
+	p.jwksURL = metadata.JWKSURI
+
+	if err := p.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// AuthCodeURL is where to send the user to sign in. state and nonce should
+// be random and remembered until the callback; codeChallenge is the S256
+// PKCE challenge.
+func (p *Provider) AuthCodeURL(state, nonce, codeChallenge string) string {
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(p.authURL, "?") {
+		sep = "&"
+	}
+	return p.authURL + sep + params.Encode()
+}
+
+// Exchange trades an authorization code for an ID token and verifies it.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (IDToken, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return IDToken{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return IDToken{}, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return IDToken{}, fmt.Errorf("token request: %w", err)
+	}
+
+	var tokens struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return IDToken{}, fmt.Errorf("token request: status %d: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return IDToken{}, fmt.Errorf("token request: status %d: %s %s", resp.StatusCode, tokens.Error, tokens.ErrorDescription)
+	}
+	if tokens.IDToken == "" {
+		return IDToken{}, errors.New("token response has no id_token")
+	}
+	return p.VerifyIDToken(ctx, tokens.IDToken, nonce)
+}
+
+// idTokenClaims are the ID token claims we check or use.
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string   `json:"nonce"`
+	AuthorizedBy  string   `json:"azp"`
+	Email         string   `json:"email"`
+	EmailVerified flexBool `json:"email_verified"`
+	Name          string   `json:"name"`
+}
+
+// flexBool accepts "true" as well as true, since some providers send
+// email_verified as a string.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	*b = flexBool(s == "true")
+	return nil
+}
+
+// VerifyIDToken checks rawToken's signature, issuer, audience, expiry and
+// nonce, as OpenID Connect Core section 3.1.3.7 requires.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawToken, nonce string) (IDToken, error) {
+	claims := idTokenClaims{}
+	_, err := jwt.ParseWithClaims(
+		rawToken,
+		&claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			key, err := p.key(ctx, kid)
+			if err != nil {
+				return nil, err
+			}
+			if !algorithmMatches(token.Method, key) {
+				return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+			}
+			return key, nil
+		},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "EdDSA"}),
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithTimeFunc(p.now),
+	)
+	if err != nil {
+		return IDToken{}, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+	// A token meant for several clients must say it was issued to us.
+	if len(claims.Audience) > 1 && claims.AuthorizedBy != p.config.ClientID {
+		return IDToken{}, fmt.Errorf("%w: azp is %q", ErrInvalidIDToken, claims.AuthorizedBy)
+	}
+	if nonce == "" || claims.Nonce != nonce {
+		return IDToken{}, fmt.Errorf("%w: nonce doesn't match", ErrInvalidIDToken)
+	}
+	if claims.Subject == "" {
+		return IDToken{}, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+	return IDToken{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+// algorithmMatches reports whether method is one key was made for, so a
+// token can't pick how its signature is checked.
+func algorithmMatches(method jwt.SigningMethod, key crypto.PublicKey) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		_, ok := method.(*jwt.SigningMethodRSA)
+		return ok
+	case *ecdsa.PublicKey:
+		m, ok := method.(*jwt.SigningMethodECDSA)
+		return ok && m.CurveBits == key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		_, ok := method.(*jwt.SigningMethodEd25519)
+		return ok
+	}
+	return false
+}
+
+// key returns the provider's key named kid. Providers rotate keys, so an
+// unknown kid fetches the key set again, at most once a keyRefreshInterval.
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	stale := p.now().Sub(p.keysFetched) >= keyRefreshInterval
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, auth.ErrUnknownKey
+	}
+
+	if err := p.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key, ok = p.keys[kid]
+	if !ok {
+		return nil, auth.ErrUnknownKey
+	}
+	return key, nil
+}
+
+// refreshKeys fetches the provider's JWKS. Keys we can't use, like
+// encryption keys or unsupported types, are skipped.
+func (p *Provider) refreshKeys(ctx context.Context) error {
+	var set auth.JWKS
+	if err := p.getJSON(ctx, p.jwksURL, &set); err != nil {
+		return fmt.Errorf("fetching keys: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys = keys
+	p.keysFetched = p.now()
+	return nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(v)
+}
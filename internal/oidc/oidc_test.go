@@ -0,0 +1,301 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mrcordova/chirpy/internal/auth"
+)
+
+const (
+	testClientID     = "chirpy"
+	testClientSecret = "s3cret"
+	testRedirectURL  = "https://chirpy.example.com/api/login/oidc/callback"
+	testCode         = "the-code"
+	testVerifier     = "a-verifier-that-is-at-least-forty-three-characters-long"
+)
+
+// testIdP is a stand-in identity provider.
+type testIdP struct {
+	*httptest.Server
+	t *testing.T
+
+	mu      sync.Mutex
+	keyID   string
+	key     *rsa.PrivateKey
+	idToken string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	idp := &testIdP{t: t}
+	idp.rotate("k1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		json.NewEncoder(w).Encode(auth.JWKS{Keys: []auth.JWK{{
+			Kty: "RSA",
+			Kid: idp.keyID,
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, _ := r.BasicAuth()
+		if clientID != testClientID || secret != testClientSecret {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		if r.PostFormValue("code") != testCode || r.PostFormValue("code_verifier") != testVerifier ||
+			r.PostFormValue("redirect_uri") != testRedirectURL {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "unused",
+			"token_type":   "Bearer",
+			"id_token":     idp.idToken,
+		})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *testIdP) rotate(keyID string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.keyID, idp.key = keyID, key
+}
+
+// sign makes an ID token with the IdP's current key and sets it as the next
+// token the token endpoint hands out.
+func (idp *testIdP) sign(claims jwt.MapClaims) string {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = idp.keyID
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	idp.idToken = signed
+	return signed
+}
+
+func (idp *testIdP) claims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            idp.URL,
+		"sub":            "user-123",
+		"aud":            testClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          "n0nce",
+		"email":          "saul@example.com",
+		"email_verified": true,
+		"name":           "Saul Goodman",
+	}
+}
+
+func discover(t *testing.T, idp *testIdP) *Provider {
+	t.Helper()
+	p, err := Discover(context.Background(), Config{
+		Issuer:       idp.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	idp := newTestIdP(t)
+	p := discover(t, idp)
+
+	u, err := url.Parse(p.AuthCodeURL("st4te", "n0nce", "ch4llenge"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Scheme + "://" + u.Host + u.Path; got != idp.URL+"/authorize" {
+		t.Errorf("AuthCodeURL() endpoint = %s", got)
+	}
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURL,
+		"scope":                 "openid email profile",
+		"state":                 "st4te",
+		"nonce":                 "n0nce",
+		"code_challenge":        "ch4llenge",
+		"code_challenge_method": "S256",
+	}
+	for key, value := range want {
+		if got := u.Query().Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestExchange(t *testing.T) {
+	idp := newTestIdP(t)
+	p := discover(t, idp)
+
+	claims := idp.claims()
+	claims["email_verified"] = "true"
+	idp.sign(claims)
+	got, err := p.Exchange(context.Background(), testCode, testVerifier, "n0nce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := IDToken{
+		Issuer:        idp.URL,
+		Subject:       "user-123",
+		Email:         "saul@example.com",
+		EmailVerified: true,
+		Name:          "Saul Goodman",
+	}
+	if got != want {
+		t.Errorf("Exchange() = %+v, want %+v", got, want)
+	}
+
+	if _, err := p.Exchange(context.Background(), "wrong-code", testVerifier, "n0nce"); err == nil {
+		t.Error("Exchange() accepted a code the IdP rejected")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	idp := newTestIdP(t)
+	p := discover(t, idp)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token func() string
+		nonce string
+	}{
+		{"Wrong nonce", func() string { return idp.sign(idp.claims()) }, "other"},
+		{"Wrong audience", func() string {
+			c := idp.claims()
+			c["aud"] = "someone-else"
+			return idp.sign(c)
+		}, "n0nce"},
+		{"Shared audience without azp", func() string {
+			c := idp.claims()
+			c["aud"] = []string{testClientID, "someone-else"}
+			return idp.sign(c)
+		}, "n0nce"},
+		{"Wrong issuer", func() string {
+			c := idp.claims()
+			c["iss"] = "https://evil.example.com"
+			return idp.sign(c)
+		}, "n0nce"},
+		{"Expired", func() string {
+			c := idp.claims()
+			c["exp"] = time.Now().Add(-time.Hour).Unix()
+			return idp.sign(c)
+		}, "n0nce"},
+		{"No expiry", func() string {
+			c := idp.claims()
+			delete(c, "exp")
+			return idp.sign(c)
+		}, "n0nce"},
+		{"Unknown key", func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, idp.claims())
+			token.Header["kid"] = "k1"
+			signed, _ := token.SignedString(otherKey)
+			return signed
+		}, "n0nce"},
+		{"HS256", func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, idp.claims())
+			token.Header["kid"] = "k1"
+			signed, _ := token.SignedString([]byte("secret"))
+			return signed
+		}, "n0nce"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.VerifyIDToken(context.Background(), tt.token(), tt.nonce)
+			if !errors.Is(err, ErrInvalidIDToken) {
+				t.Errorf("VerifyIDToken() error = %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	idp := newTestIdP(t)
+	p := discover(t, idp)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	idp.rotate("k2")
+	token := idp.sign(idp.claims())
+
+	// Keys were just fetched, so an unknown kid doesn't fetch them again yet.
+	if _, err := p.VerifyIDToken(context.Background(), token, "n0nce"); !errors.Is(err, auth.ErrUnknownKey) {
+		t.Fatalf("VerifyIDToken() error = %v, want ErrUnknownKey", err)
+	}
+
+	now = now.Add(keyRefreshInterval)
+	if _, err := p.VerifyIDToken(context.Background(), token, "n0nce"); err != nil {
+		t.Errorf("VerifyIDToken() after rotation: %v", err)
+	}
+}
+
+func TestDiscoverRejectsOtherIssuer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 "https://evil.example.com",
+			"authorization_endpoint": "https://evil.example.com/authorize",
+			"token_endpoint":         "https://evil.example.com/token",
+			"jwks_uri":               "https://evil.example.com/jwks",
+		})
+	}))
+	defer srv.Close()
+
+	_, err := Discover(context.Background(), Config{Issuer: srv.URL, ClientID: testClientID})
+	if err == nil || !strings.Contains(err.Error(), "issuer") {
+		t.Errorf("Discover() error = %v, want an issuer mismatch", err)
+	}
+}
@@ -0,0 +1,445 @@
+// Package qr encodes short strings, such as otpauth:// URIs, as QR codes.
+// It only supports byte mode at error correction level M, in versions 1
+// to 10, which holds up to 213 bytes.
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// ErrTooLong is returned for data that doesn't fit in a version 10 code.
+var ErrTooLong = errors.New("data too long for QR code")
+
+// quietZone is the light border, in modules, the spec requires around a code.
+const quietZone = 4
+
+// versionInfo describes the error correction blocks for a version at level
+// M. Blocks in the second group hold one more data codeword than the first.
+type versionInfo struct {
+	ecPerBlock int
+	group1     int
+	data1      int
+	group2     int
+	alignment  []int
+}
+
+var versions = []versionInfo{
+	1:  {ecPerBlock: 10, group1: 1, data1: 16},
+	2:  {ecPerBlock: 16, group1: 1, data1: 28, alignment: []int{6, 18}},
+	3:  {ecPerBlock: 26, group1: 1, data1: 44, alignment: []int{6, 22}},
+	4:  {ecPerBlock: 18, group1: 2, data1: 32, alignment: []int{6, 26}},
+	5:  {ecPerBlock: 24, group1: 2, data1: 43, alignment: []int{6, 30}},
+	6:  {ecPerBlock: 16, group1: 4, data1: 27, alignment: []int{6, 34}},
+	7:  {ecPerBlock: 18, group1: 4, data1: 31, alignment: []int{6, 22, 38}},
+	8:  {ecPerBlock: 22, group1: 2, data1: 38, group2: 2, alignment: []int{6, 24, 42}},
+	9:  {ecPerBlock: 22, group1: 3, data1: 36, group2: 2, alignment: []int{6, 26, 46}},
+	10: {ecPerBlock: 26, group1: 4, data1: 43, group2: 1, alignment: []int{6, 28, 50}},
+}
+
+func (v versionInfo) dataCodewords() int {
+	return v.group1*v.data1 + v.group2*(v.data1+1)
+}
+
+// Code is an encoded QR code. Modules[y][x] is true for dark modules.
+type Code struct {
+	Version int
+	Size    int
+	Modules [][]bool
+
+	function [][]bool
+}
+
+// Encode returns the smallest QR code that holds data.
+func Encode(data []byte) (*Code, error) {
+	version := 0
+	for v := 1; v < len(versions); v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= 8*versions[v].dataCodewords() {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	c := newCode(version)
+	c.drawFunctionPatterns()
+	c.drawCodewords(interleave(versions[version], encodeData(version, data)))
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask)
+	}
+	c.applyMask(best)
+	c.drawFormatBits(best)
+	return c, nil
+}
+
+// PNG renders the code with scale pixels per module and a quiet zone.
+func (c *Code) PNG(scale int) ([]byte, error) {
+	size := (c.Size + 2*quietZone) * scale
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for y, row := range c.Modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetGray((x+quietZone)*scale+dx, (y+quietZone)*scale+dy, color.Gray{})
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func newCode(version int) *Code {
+	size := 17 + 4*version
+	c := &Code{Version: version, Size: size}
+	c.Modules = make([][]bool, size)
+	c.function = make([][]bool, size)
+	for i := range c.Modules {
+		c.Modules[i] = make([]bool, size)
+		c.function[i] = make([]bool, size)
+	}
+	return c
+}
+
+func (c *Code) set(x, y int, dark bool) {
+	c.Modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+	for i := 0; i < c.Size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+
+	align := versions[c.Version].alignment
+	for i, x := range align {
+		for j, y := range align {
+			// Skip the three corners taken by finder patterns.
+			if (i == 0 && j == 0) || (i == 0 && j == len(align)-1) || (i == len(align)-1 && j == 0) {
+				continue
+			}
+			c.drawAlignment(x, y)
+		}
+	}
+
+	// Reserve the format areas; the real bits are drawn once a mask is chosen.
+	c.drawFormatBits(0)
+	c.drawVersion()
+}
+
+// drawFinder draws a finder pattern centred on (x, y) with its separator.
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= c.Size || yy < 0 || yy >= c.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.set(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (c *Code) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// formatBits returns the 15-bit format information for level M and mask.
+func formatBits(mask int) int {
+	const levelM = 0
+	data := levelM<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (c *Code) drawFormatBits(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return (bits>>i)&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true)
+}
+
+// versionBits returns the 18-bit version information, used from version 7.
+func versionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1f25)
+	}
+	return version<<12 | rem
+}
+
+func (c *Code) drawVersion() {
+	if c.Version < 7 {
+		return
+	}
+	bits := versionBits(c.Version)
+	for i := 0; i < 18; i++ {
+		dark := (bits>>i)&1 != 0
+		a, b := c.Size-11+i%3, i/3
+		c.set(a, b, dark)
+		c.set(b, a, dark)
+	}
+}
+
+// encodeData builds the data codewords: a byte mode header, the data,
+// a terminator and padding up to the version's capacity.
+func encodeData(version int, data []byte) []byte {
+	var bits bitBuffer
+	bits.append(0b0100, 4)
+	if version >= 10 {
+		bits.append(len(data), 16)
+	} else {
+		bits.append(len(data), 8)
+	}
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+
+	capacity := 8 * versions[version].dataCodewords()
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xec; len(bits) < capacity; pad ^= 0xec ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	return bits.bytes()
+}
+
+// interleave splits data into blocks, adds error correction to each and
+// interleaves the result as the spec requires.
+func interleave(v versionInfo, data []byte) []byte {
+	blocks := [][]byte{}
+	ecBlocks := [][]byte{}
+	gen := rsGenerator(v.ecPerBlock)
+	for i, offset := 0, 0; i < v.group1+v.group2; i++ {
+		n := v.data1
+		if i >= v.group1 {
+			n++
+		}
+		block := data[offset : offset+n]
+		offset += n
+		blocks = append(blocks, block)
+		ecBlocks = append(ecBlocks, rsRemainder(block, gen))
+	}
+
+	result := make([]byte, 0, len(data)+len(blocks)*v.ecPerBlock)
+	for i := 0; i <= v.data1; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < v.ecPerBlock; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+// drawCodewords places data in the zigzag order, two columns at a time
+// from the bottom right, skipping function modules.
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if c.function[y][x] || i >= len(data)*8 {
+					continue
+				}
+				c.Modules[y][x] = (data[i>>3]>>(7-i&7))&1 != 0
+				i++
+			}
+		}
+	}
+}
+
+// applyMask flips the non-function modules selected by mask. Applying the
+// same mask twice undoes it.
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.function[y][x] {
+				continue
+			}
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (x+y)%2 == 0
+			case 1:
+				flip = y%2 == 0
+			case 2:
+				flip = x%3 == 0
+			case 3:
+				flip = (x+y)%3 == 0
+			case 4:
+				flip = (x/3+y/2)%2 == 0
+			case 5:
+				flip = x*y%2+x*y%3 == 0
+			case 6:
+				flip = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				flip = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if flip {
+				c.Modules[y][x] = !c.Modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code would be to scan, using the four rules
+// from the spec. Lower is better.
+func (c *Code) penalty() int {
+	score := 0
+	get := func(x, y int, vertical bool) bool {
+		if vertical {
+			return c.Modules[x][y]
+		}
+		return c.Modules[y][x]
+	}
+
+	finderLike := [][]bool{
+		{true, false, true, true, true, false, true, false, false, false, false},
+		{false, false, false, false, true, false, true, true, true, false, true},
+	}
+	for _, vertical := range []bool{false, true} {
+		for y := 0; y < c.Size; y++ {
+			run := 1
+			for x := 1; x < c.Size; x++ {
+				if get(x, y, vertical) == get(x-1, y, vertical) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					score += run - 2
+				}
+				run = 1
+			}
+			if run >= 5 {
+				score += run - 2
+			}
+
+			for x := 0; x+11 <= c.Size; x++ {
+				for _, pattern := range finderLike {
+					match := true
+					for k, dark := range pattern {
+						if get(x+k, y, vertical) != dark {
+							match = false
+							break
+						}
+					}
+					if match {
+						score += 40
+					}
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Modules[y][x] {
+				dark++
+			}
+			if x+1 < c.Size && y+1 < c.Size {
+				m := c.Modules[y][x]
+				if m == c.Modules[y][x+1] && m == c.Modules[y+1][x] && m == c.Modules[y+1][x+1] {
+					score += 3
+				}
+			}
+		}
+	}
+	percent := dark * 100 / (c.Size * c.Size)
+	score += abs(percent-50) / 5 * 10
+	return score
+}
+
+type bitBuffer []bool
+
+func (b *bitBuffer) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, (value>>i)&1 != 0)
+	}
+}
+
+func (b bitBuffer) bytes() []byte {
+	out := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			out[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return out
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
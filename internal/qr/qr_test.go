@@ -0,0 +1,185 @@
+package qr
+
+import (
+	"bytes"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRSRemainder(t *testing.T) {
+	// The 1-M "HELLO WORLD" example from the QR code tutorial at thonky.com.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsGenerator(10)); !reflect.DeepEqual(got, want) {
+		t.Errorf("rsRemainder() = %v, want %v", got, want)
+	}
+}
+
+func TestFormatBits(t *testing.T) {
+	// From the format information table in ISO/IEC 18004.
+	tests := map[int]int{
+		0: 0b101010000010010,
+		1: 0b101000100100101,
+		5: 0b100000011001110,
+		7: 0b100101010100000,
+	}
+	for mask, want := range tests {
+		if got := formatBits(mask); got != want {
+			t.Errorf("formatBits(%d) = %015b, want %015b", mask, got, want)
+		}
+	}
+}
+
+func TestVersionBits(t *testing.T) {
+	tests := map[int]int{7: 0x07c94, 8: 0x085bc, 9: 0x09a99, 10: 0x0a4d3}
+	for version, want := range tests {
+		if got := versionBits(version); got != want {
+			t.Errorf("versionBits(%d) = %#x, want %#x", version, got, want)
+		}
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	tests := []string{
+		"hi",
+		"otpauth://totp/Chirpy:boots@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Chirpy",
+		strings.Repeat("x", 200),
+	}
+	for _, input := range tests {
+		code, err := Encode([]byte(input))
+		if err != nil {
+			t.Fatalf("Encode(%d bytes) error = %v", len(input), err)
+		}
+		if got := decode(t, code); got != input {
+			t.Errorf("decode(Encode(%q)) = %q", input, got)
+		}
+	}
+}
+
+func TestEncodeTooLong(t *testing.T) {
+	if _, err := Encode(bytes.Repeat([]byte("x"), 214)); err != ErrTooLong {
+		t.Errorf("Encode() error = %v, want ErrTooLong", err)
+	}
+}
+
+func TestPNG(t *testing.T) {
+	code, err := Encode([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := code.PNG(4)
+	if err != nil {
+		t.Fatalf("PNG() error = %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+	want := (code.Size + 2*quietZone) * 4
+	if img.Bounds().Dx() != want || img.Bounds().Dy() != want {
+		t.Errorf("image size = %v, want %dx%d", img.Bounds(), want, want)
+	}
+}
+
+// decode reads a code back: it finds the mask from the format bits, reads
+// the codewords in placement order, checks each block's error correction
+// and parses the byte mode segment.
+func decode(t *testing.T, code *Code) string {
+	t.Helper()
+
+	mask := -1
+	for m := 0; m < 8; m++ {
+		bits := formatBits(m)
+		match := true
+		for i := 0; i <= 5; i++ {
+			if code.Modules[i][8] != ((bits>>i)&1 != 0) {
+				match = false
+			}
+		}
+		if match {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatal("couldn't read format bits")
+	}
+
+	layout := newCode(code.Version)
+	layout.drawFunctionPatterns()
+	unmasked := newCode(code.Version)
+	for y := range code.Modules {
+		copy(unmasked.Modules[y], code.Modules[y])
+	}
+	unmasked.function = layout.function
+	unmasked.applyMask(mask)
+
+	var bits bitBuffer
+	for right := code.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < code.Size; vert++ {
+			y := vert
+			if upward {
+				y = code.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				if x := right - j; !layout.function[y][x] {
+					bits = append(bits, unmasked.Modules[y][x])
+				}
+			}
+		}
+	}
+	codewords := bits.bytes()
+
+	v := versions[code.Version]
+	numBlocks := v.group1 + v.group2
+	blocks := make([][]byte, numBlocks)
+	i := 0
+	for k := 0; k <= v.data1; k++ {
+		for b := range blocks {
+			if k < v.data1 || b >= v.group1 {
+				blocks[b] = append(blocks[b], codewords[i])
+				i++
+			}
+		}
+	}
+	gen := rsGenerator(v.ecPerBlock)
+	for k := 0; k < v.ecPerBlock; k++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], codewords[i])
+			i++
+		}
+	}
+
+	var data []byte
+	for _, block := range blocks {
+		n := len(block) - v.ecPerBlock
+		if got := rsRemainder(block[:n], gen); !bytes.Equal(got, block[n:]) {
+			t.Fatalf("block error correction = %v, want %v", block[n:], got)
+		}
+		data = append(data, block[:n]...)
+	}
+
+	if data[0]>>4 != 0b0100 {
+		t.Fatalf("mode = %04b, want byte mode", data[0]>>4)
+	}
+	if code.Version >= 10 {
+		n := int(data[0]&0xf)<<12 | int(data[1])<<4 | int(data[2]>>4)
+		return string(shift(data[2:2+n+1])[:n])
+	}
+	n := int(data[0]&0xf)<<4 | int(data[1]>>4)
+	return string(shift(data[1 : 1+n+1])[:n])
+}
+
+// shift drops the first four bits of b.
+func shift(b []byte) []byte {
+	out := make([]byte, len(b)-1)
+	for i := range out {
+		out[i] = b[i]<<4 | b[i+1]>>4
+	}
+	return out
+}
@@ -0,0 +1,51 @@
+package qr
+
+// Reed-Solomon error correction over GF(2^8) with the QR code polynomial
+// x^8 + x^4 + x^3 + x^2 + 1.
+
+func gfMultiply(x, y byte) byte {
+	var z byte
+	for i := 7; i >= 0; i-- {
+		carry := z >> 7
+		z <<= 1
+		if carry != 0 {
+			z ^= 0x1d
+		}
+		if (y>>i)&1 != 0 {
+			z ^= x
+		}
+	}
+	return z
+}
+
+// rsGenerator returns the coefficients of the generator polynomial of the
+// given degree, highest power first with the leading 1 left out.
+func rsGenerator(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	var root byte = 1
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder returns the error correction codewords for data.
+func rsRemainder(data, generator []byte) []byte {
+	result := make([]byte, len(generator))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range generator {
+			result[i] ^= gfMultiply(coef, factor)
+		}
+	}
+	return result
+}
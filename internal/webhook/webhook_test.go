@@ -0,0 +1,41 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	const secret = "whsec"
+	body := []byte(`{"id":"evt_1","event":"user.upgraded"}`)
+	sentAt := time.Unix(1700000000, 0)
+	header := Sign(secret, sentAt, body)
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		now    time.Time
+		want   error
+	}{
+		{"Valid", secret, header, body, sentAt.Add(time.Minute), nil},
+		{"Rotated secret", secret, header[:len("t=1700000000")] + ",v1=00," + header[len("t=1700000000,"):], body, sentAt, nil},
+		{"Wrong secret", "other", header, body, sentAt, ErrInvalidSignature},
+		{"Tampered body", secret, header, []byte(`{"id":"evt_1","event":"user.downgraded"}`), sentAt, ErrInvalidSignature},
+		{"Too old", secret, header, body, sentAt.Add(DefaultTolerance + time.Second), ErrTimestampExpired},
+		{"From the future", secret, header, body, sentAt.Add(-DefaultTolerance - time.Second), ErrTimestampExpired},
+		{"Missing", secret, "", body, sentAt, ErrMissingSignature},
+		{"No timestamp", secret, "v1=abc", body, sentAt, ErrMissingSignature},
+		{"Timestamp changed", secret, "t=1700000001" + header[len("t=1700000000"):], body, sentAt, ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, tt.now, DefaultTolerance)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
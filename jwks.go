@@ -0,0 +1,14 @@
+package main
+
+import "net/http"
+
+// handlerJWKS publishes the public keys access tokens are signed with, so
+// other services can verify them without holding a secret. The same keys
+// sign tokens that aren't access tokens, so verifiers must also check the
+// typ header is "at+jwt" and the aud claim is "chirpy".
+func (cfg *apiConfig) handlerJWKS(w http.ResponseWriter, r *http.Request) {
+	// Verifiers cache this, so a new key should be added a while before it
+	// starts signing.
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, cfg.jwtKeys.JWKS())
+}
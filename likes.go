@@ -0,0 +1,213 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/auth"
+	"github.com/mrcordova/chirpy/internal/database"
+)
+
+// viewerID returns the caller's user ID when the request was authenticated
+// with the chirps:read scope. Endpoints that work without auth use it to
+// personalise their responses.
+func viewerID(r *http.Request) (uuid.UUID, bool) {
+	p := principalFrom(r.Context())
+	if p.UserID == uuid.Nil || !auth.HasScope(p.Scopes, auth.ScopeChirpsRead) {
+		return uuid.Nil, false
+	}
+	return p.UserID, true
+}
+
+// fillLikedByMe sets liked_by_me on each chirp for the caller. It leaves the
+// field unset for anonymous requests.
+func (cfg *apiConfig) fillLikedByMe(r *http.Request, chirps []*Chirp) error {
+	userID, ok := viewerID(r)
+	if !ok || len(chirps) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.Id)
+	}
+	likedIDs, err := cfg.db.ListLikedChirpIDs(r.Context(), database.ListLikedChirpIDsParams{
+		UserID:   userID,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return err
+	}
+
+	liked := make(map[uuid.UUID]bool, len(likedIDs))
+	for _, id := range likedIDs {
+		liked[id] = true
+	}
+	for _, chirp := range chirps {
+		likedByMe := liked[chirp.Id]
+		chirp.LikedByMe = &likedByMe
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerChirpLike(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r.Context()).UserID
+	if !cfg.requireVerified(w, r, userID, actionLike) {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't like chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	dbChirp, err := qtx.GetChirp(r.Context(), chirpID)
+	if err != nil || dbChirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+
+	added, err := qtx.LikeChirp(r.Context(), database.LikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't like chirp", err)
+		return
+	}
+	if added > 0 {
+		if err := qtx.IncrementLikeCount(r.Context(), chirpID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't like chirp", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't like chirp", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerChirpUnlike(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r.Context()).UserID
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unlike chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	removed, err := qtx.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unlike chirp", err)
+		return
+	}
+	if removed == 0 {
+		respondWithError(w, http.StatusNotFound, "Chirp not liked", nil)
+		return
+	}
+	if err := qtx.DecrementLikeCount(r.Context(), chirpID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unlike chirp", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unlike chirp", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUserLikes(w http.ResponseWriter, r *http.Request) {
+	type likedChirp struct {
+		chirp   database.Chirp
+		likedAt time.Time
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+	limit, cursor, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	cursorCreatedAt, cursorID := cursorArgs(cursor)
+
+	likes := []likedChirp{}
+	if cursor != nil && cursor.Direction == cursorPrev {
+		rows, err := cfg.db.ListUserLikesAfter(r.Context(), database.ListUserLikesAfterParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       int32(limit + 1),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve likes", err)
+			return
+		}
+		for _, row := range rows {
+			likes = append(likes, likedChirp{chirp: row.Chirp, likedAt: row.LikedAt})
+		}
+	} else {
+		rows, err := cfg.db.ListUserLikesBefore(r.Context(), database.ListUserLikesBeforeParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       int32(limit + 1),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve likes", err)
+			return
+		}
+		for _, row := range rows {
+			likes = append(likes, likedChirp{chirp: row.Chirp, likedAt: row.LikedAt})
+		}
+	}
+
+	page, next, prev := paginate(likes, limit, cursor, func(l likedChirp) (time.Time, uuid.UUID) {
+		return l.likedAt, l.chirp.ID
+	})
+
+	dbChirps := make([]database.Chirp, 0, len(page))
+	for _, like := range page {
+		dbChirps = append(dbChirps, like.chirp)
+	}
+	chirps := chirpsFromDB(dbChirps)
+	if err := cfg.decorateChirps(r, chirpRefs(chirps)); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve likes", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirpPage{
+		Chirps:     chirps,
+		NextCursor: next,
+		PrevCursor: prev,
+	})
+}
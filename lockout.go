@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/auth"
+	"github.com/mrcordova/chirpy/internal/database"
+)
+
+// Failures are counted separately per account and per client IP. An IP can
+// fail more often before it's locked out, since several people may share it.
+var (
+	accountLockout = auth.LockoutPolicy{Threshold: 5, Base: 30 * time.Second, Max: 15 * time.Minute}
+	ipLockout      = auth.LockoutPolicy{Threshold: 20, Base: 30 * time.Second, Max: time.Hour}
+)
+
+// loginFailureReset is how long after the last failure the count starts over.
+const loginFailureReset = 24 * time.Hour
+
+// dummyPasswordHash is checked against when the email doesn't belong to an
+// account, so unknown emails take as long to reject as wrong passwords.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := auth.HashPassword("not a real password")
+	return hash
+})
+
+// Accounts are keyed by email rather than user ID so that failures against
+// emails with no account are tracked, and locked out, the same way.
+func accountLoginKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipLoginKey(ip string) string {
+	return "ip:" + ip
+}
+
+// lockout is a failure counter and the policy for locking it out.
+type lockout struct {
+	key    string
+	policy auth.LockoutPolicy
+}
+
+func loginLockouts(email, ip string) []lockout {
+	return []lockout{
+		{accountLoginKey(email), accountLockout},
+		{ipLoginKey(ip), ipLockout},
+	}
+}
+
+// lockedUntil returns when the last of lockouts ends, or the zero time if
+// none of them is locked out.
+func (cfg *apiConfig) lockedUntil(ctx context.Context, lockouts []lockout) (time.Time, error) {
+	keys := make([]string, 0, len(lockouts))
+	for _, l := range lockouts {
+		keys = append(keys, l.key)
+	}
+	locked, err := cfg.db.GetLoginLockouts(ctx, database.GetLoginLockoutsParams{
+		Keys: keys,
+		Now:  time.Now().UTC(),
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	var until time.Time
+	for _, l := range locked {
+		if l.LockedUntil.Time.After(until) {
+			until = l.LockedUntil.Time
+		}
+	}
+	return until, nil
+}
+
+// checkLockout writes a 429 with msg and returns false if any of lockouts
+// is locked out.
+func (cfg *apiConfig) checkLockout(w http.ResponseWriter, r *http.Request, lockouts []lockout, msg string) bool {
+	until, err := cfg.lockedUntil(r.Context(), lockouts)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check rate limits", err)
+		return false
+	}
+	if until.IsZero() {
+		return true
+	}
+
+	retryAfter := int(math.Ceil(time.Until(until).Seconds()))
+	w.Header().Set("Retry-After", fmt.Sprint(retryAfter))
+	respondWithError(w, http.StatusTooManyRequests, msg, nil)
+	return false
+}
+
+// checkLoginLockout writes a 429 and returns false if the account or client
+// is locked out.
+func (cfg *apiConfig) checkLoginLockout(w http.ResponseWriter, r *http.Request, email string) bool {
+	return cfg.checkLockout(w, r, loginLockouts(email, clientIP(r)), "Too many failed login attempts, try again later")
+}
+
+var errIncorrectPassword = errors.New("Incorrect email or password")
+
+// checkPassword returns the account for email if password is right. Unknown
+// emails and wrong passwords both give errIncorrectPassword, and take about
+// as long, so logins can't be used to find out who has an account. Either
+// counts as a failed login.
+func (cfg *apiConfig) checkPassword(r *http.Request, email, password string) (database.User, error) {
+	user, err := cfg.db.GetUser(r.Context(), email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+	hashedPassword := user.HashedPassword
+	if err != nil {
+		hashedPassword = dummyPasswordHash()
+	}
+	if err := auth.CheckPasswordHash(password, hashedPassword); err != nil || user.ID == uuid.Nil {
+		if err := cfg.recordLoginFailure(r.Context(), email, clientIP(r)); err != nil {
+			return database.User{}, err
+		}
+		return database.User{}, errIncorrectPassword
+	}
+	return user, nil
+}
+
+// checkCurrentPassword writes an error and returns false unless password is
+// user's. Wrong guesses count as failed logins, so someone holding an
+// access token can't use it to guess the password without limit.
+func (cfg *apiConfig) checkCurrentPassword(w http.ResponseWriter, r *http.Request, user database.User, password string) bool {
+	if !cfg.checkLoginLockout(w, r, user.Email) {
+		return false
+	}
+	if err := auth.CheckPasswordHash(password, user.HashedPassword); err != nil {
+		if err := cfg.recordLoginFailure(r.Context(), user.Email, clientIP(r)); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't check password", err)
+			return false
+		}
+		respondWithError(w, http.StatusUnauthorized, "Current password is incorrect", err)
+		return false
+	}
+	return true
+}
+
+// recordLoginFailure counts a failed login against the account and the
+// client, locking either out once it has failed too often.
+func (cfg *apiConfig) recordLoginFailure(ctx context.Context, email, ip string) error {
+	return cfg.recordFailures(ctx, loginLockouts(email, ip), loginFailureReset)
+}
+
+// recordFailures counts a failure against each of lockouts and locks out
+// any that have failed too often. A count starts over once reset has passed
+// since its last failure.
+func (cfg *apiConfig) recordFailures(ctx context.Context, lockouts []lockout, reset time.Duration) error {
+	now := time.Now().UTC()
+	for _, l := range lockouts {
+		failure, err := cfg.db.RecordLoginFailure(ctx, database.RecordLoginFailureParams{
+			Key:         l.key,
+			Now:         now,
+			ResetBefore: now.Add(-reset),
+		})
+		if err != nil {
+			return err
+		}
+		delay := l.policy.Delay(int(failure.Failures))
+		if delay == 0 {
+			continue
+		}
+		err = cfg.db.LockLogin(ctx, database.LockLoginParams{
+			Key:         l.key,
+			LockedUntil: sql.NullTime{Time: now.Add(delay), Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerAdminUnlockUser(w http.ResponseWriter, r *http.Request) {
+	if !cfg.requireAdmin(w, r) {
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+
+	if _, err := cfg.db.ClearLoginFailures(r.Context(), accountLoginKey(user.Email)); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unlock account", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
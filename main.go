@@ -1,31 +1,58 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
-	"sort"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/mrcordova/chirpy/internal/auth"
 	"github.com/mrcordova/chirpy/internal/database"
+	"github.com/mrcordova/chirpy/internal/mailer"
+	"github.com/mrcordova/chirpy/internal/media"
+	"github.com/mrcordova/chirpy/internal/moderation"
+	"github.com/mrcordova/chirpy/internal/oauth"
+	"github.com/mrcordova/chirpy/internal/oidc"
+)
+
+const (
+	accessTokenDuration  = time.Hour
+	refreshTokenDuration = time.Hour * 24 * 60
 )
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	db *database.Queries
+	dbConn *sql.DB
 	platform string
-	jwtSecret string
+	jwtKeys *auth.KeySet
 	polkaApiKey string
+	adminApiKey string
+	moderation *moderation.Pipeline
+	wordFilter *moderation.WordFilter
+	wordlistFile string
+	chirpEditWindow time.Duration
+	storage media.Storage
+	mediaMaxBytes int64
+	mailer mailer.Mailer
+	appBaseURL string
+	unverifiedRestrictions map[string]bool
+	accountDeletionGrace time.Duration
+	exports media.Storage
+	oidc *oidc.Provider
+	oidcFrontendURL *url.URL
 }
 
 type User struct {
@@ -33,7 +60,14 @@ type User struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Email     string    `json:"email"`
+	EmailVerified bool `json:"email_verified"`
 	IsChirpyRed bool `json:"is_chirpy_red"`
+	Handle *string `json:"handle"`
+	DisplayName string `json:"display_name"`
+	Bio string `json:"bio"`
+	Location string `json:"location"`
+	AvatarURL *string `json:"avatar_url"`
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
 	Password  string    `json:"-"`
 }
 type Chirp struct {
@@ -41,7 +75,71 @@ type Chirp struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Body string `json:"body"`
-	UserId uuid.UUID `json:"user_id"`
+	// UserId is nil for tombstones left behind by a purged account.
+	UserId *uuid.UUID `json:"user_id"`
+	Author *Author `json:"author,omitempty"`
+	InReplyToId *uuid.UUID `json:"in_reply_to_id,omitempty"`
+	ReplyCount int32 `json:"reply_count"`
+	LikeCount int32 `json:"like_count"`
+	LikedByMe *bool `json:"liked_by_me,omitempty"`
+	RechirpOf *Chirp `json:"rechirp_of,omitempty"`
+	QuotedChirp *Chirp `json:"quoted_chirp,omitempty"`
+	Entities ChirpEntities `json:"entities"`
+	Media []Media `json:"media"`
+	Edited bool `json:"edited"`
+	Deleted bool `json:"deleted,omitempty"`
+
+	rechirpOfID   uuid.NullUUID
+	quotedChirpID uuid.NullUUID
+}
+
+type chirpPage struct {
+	Chirps     []Chirp `json:"chirps"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	PrevCursor string  `json:"prev_cursor,omitempty"`
+}
+
+func chirpFromDB(dbChirp database.Chirp) Chirp {
+	chirp := Chirp{
+		Id:         dbChirp.ID,
+		CreatedAt:  dbChirp.CreatedAt,
+		UpdatedAt:  dbChirp.UpdatedAt,
+		Body:       dbChirp.Body,
+		ReplyCount: dbChirp.ReplyCount,
+		LikeCount:  dbChirp.LikeCount,
+		Entities:   chirpEntities(dbChirp.Body),
+		Media:      []Media{},
+		Edited:     dbChirp.EditedAt.Valid,
+		Deleted:    dbChirp.DeletedAt.Valid,
+
+		rechirpOfID:   dbChirp.RechirpOfID,
+		quotedChirpID: dbChirp.QuotedChirpID,
+	}
+	if dbChirp.UserID.Valid {
+		chirp.UserId = &dbChirp.UserID.UUID
+	}
+	if dbChirp.InReplyToID.Valid {
+		chirp.InReplyToId = &dbChirp.InReplyToID.UUID
+	}
+	return chirp
+}
+
+func chirpsFromDB(dbChirps []database.Chirp) []Chirp {
+	chirps := make([]Chirp, 0, len(dbChirps))
+	for _, dbChirp := range dbChirps {
+		chirps = append(chirps, chirpFromDB(dbChirp))
+	}
+	return chirps
+}
+
+// chirpRefs returns pointers into chirps so helpers can fill in
+// viewer-specific fields.
+func chirpRefs(chirps []Chirp) []*Chirp {
+	refs := make([]*Chirp, 0, len(chirps))
+	for i := range chirps {
+		refs = append(refs, &chirps[i])
+	}
+	return refs
 }
 
 func main() {
@@ -66,34 +164,248 @@ func main() {
 	apiCfg := apiConfig{
 		fileserverHits: atomic.Int32{},
 		db:             dbQueries,
+		dbConn:         dbConn,
 		platform: os.Getenv("PLATFORM"),
-		jwtSecret: os.Getenv("JWT_SECRET"),
 		polkaApiKey: os.Getenv("POLKA_API_KEY"),
+		adminApiKey: os.Getenv("ADMIN_API_KEY"),
+		wordlistFile: os.Getenv("MODERATION_WORDLIST_FILE"),
+		chirpEditWindow: defaultChirpEditWindow,
+		mediaMaxBytes: defaultMediaMaxBytes,
+		accountDeletionGrace: defaultAccountDeletionGrace,
 	}
 
+	if s := os.Getenv("CHIRP_EDIT_WINDOW"); s != "" {
+		window, err := time.ParseDuration(s)
+		if err != nil {
+			log.Fatalf("Invalid CHIRP_EDIT_WINDOW: %s", err)
+		}
+		apiCfg.chirpEditWindow = window
+	}
+
+	// JWT_KEYS_DIR holds one <kid>.pem private key per file, and JWT_KEY_ID
+	// names the one that signs if there are several. To rotate, add a new
+	// key, wait for verifiers' cached JWKS to expire, then point
+	// JWT_KEY_ID at it. Leave the old key until tokens it signed expire.
+	if keysDir := os.Getenv("JWT_KEYS_DIR"); keysDir != "" {
+		apiCfg.jwtKeys, err = auth.LoadKeySet(keysDir, os.Getenv("JWT_KEY_ID"))
+		if err != nil {
+			log.Fatalf("Error loading JWT keys: %s", err)
+		}
+	} else if apiCfg.platform == "dev" {
+		log.Printf("JWT_KEYS_DIR isn't set, signing tokens with a temporary key")
+		apiCfg.jwtKeys, err = auth.GenerateKeySet()
+		if err != nil {
+			log.Fatalf("Error generating JWT key: %s", err)
+		}
+	} else {
+		log.Fatal("JWT_KEYS_DIR must be set")
+	}
+
+	apiCfg.wordFilter = moderation.NewWordFilter(nil)
+	if err := apiCfg.loadModerationWords(context.Background()); err != nil {
+		log.Fatalf("Error loading moderation wordlist: %s", err)
+	}
+	apiCfg.moderation = moderation.NewPipeline(apiCfg.wordFilter)
+
+	mediaRoot := os.Getenv("MEDIA_ROOT")
+	if mediaRoot == "" {
+		mediaRoot = "./media"
+	}
+	apiCfg.storage, err = media.NewLocalStorage(mediaRoot, "/media")
+	if err != nil {
+		log.Fatalf("Error opening media storage: %s", err)
+	}
+	if s := os.Getenv("MEDIA_MAX_BYTES"); s != "" {
+		maxBytes, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || maxBytes < 1 {
+			log.Fatalf("Invalid MEDIA_MAX_BYTES: %q", s)
+		}
+		apiCfg.mediaMaxBytes = maxBytes
+	}
+
+	apiCfg.appBaseURL = strings.TrimSuffix(os.Getenv("APP_BASE_URL"), "/")
+	if apiCfg.appBaseURL == "" {
+		apiCfg.appBaseURL = "http://localhost:" + port
+	}
+	mailFrom := os.Getenv("MAIL_FROM")
+	if mailFrom == "" {
+		mailFrom = "chirpy@localhost"
+	}
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		smtpAddr := os.Getenv("SMTP_ADDR")
+		if smtpAddr == "" {
+			log.Fatal("SMTP_ADDR must be set when MAILER=smtp")
+		}
+		apiCfg.mailer = mailer.NewSMTPMailer(smtpAddr, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), mailFrom)
+	case "file":
+		mailDir := os.Getenv("MAIL_DIR")
+		if mailDir == "" {
+			mailDir = "./mail"
+		}
+		apiCfg.mailer, err = mailer.NewFileMailer(mailDir, mailFrom)
+		if err != nil {
+			log.Fatalf("Error opening mail directory: %s", err)
+		}
+	case "", "log":
+		apiCfg.mailer = mailer.NewLogMailer(nil)
+	default:
+		log.Fatalf("Unknown MAILER %q", os.Getenv("MAILER"))
+	}
+
+	apiCfg.unverifiedRestrictions, err = parseRestrictions(os.Getenv("UNVERIFIED_RESTRICTIONS"))
+	if err != nil {
+		log.Fatalf("Invalid UNVERIFIED_RESTRICTIONS: %s", err)
+	}
+
+	// A grace period of 0 deletes accounts as soon as they're closed.
+	if s := os.Getenv("ACCOUNT_DELETION_GRACE"); s != "" {
+		grace, err := time.ParseDuration(s)
+		if err != nil || grace < 0 {
+			log.Fatalf("Invalid ACCOUNT_DELETION_GRACE: %q", s)
+		}
+		apiCfg.accountDeletionGrace = grace
+	}
+	exportRoot := os.Getenv("EXPORT_ROOT")
+	if exportRoot == "" {
+		exportRoot = "./exports"
+	}
+	apiCfg.exports, err = media.NewLocalStorage(exportRoot, "")
+	if err != nil {
+		log.Fatalf("Error opening export storage: %s", err)
+	}
+	// Exports are built in the background, so any left pending died with
+	// the last process.
+	if err := dbQueries.FailPendingDataExports(context.Background()); err != nil {
+		log.Fatalf("Error resetting data exports: %s", err)
+	}
+	go apiCfg.runMaintenance(context.Background(), maintenanceInterval)
+
+	// OIDC_ISSUER turns on signing in with an external identity provider,
+	// which Chirpy must be registered with as a confidential client.
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		if os.Getenv("OIDC_CLIENT_ID") == "" {
+			log.Fatal("OIDC_CLIENT_ID must be set when OIDC_ISSUER is")
+		}
+		redirectURL := os.Getenv("OIDC_REDIRECT_URL")
+		if redirectURL == "" {
+			redirectURL = apiCfg.appBaseURL + "/api/login/oidc/callback"
+		}
+		// OIDC_FRONTEND_URL is the page the callback sends the browser on
+		// to, with a code it trades for tokens at POST /api/login/oidc/token
+		// or an error to show.
+		frontendURL := os.Getenv("OIDC_FRONTEND_URL")
+		if frontendURL == "" {
+			frontendURL = apiCfg.appBaseURL + "/app/login/oidc"
+		}
+		apiCfg.oidcFrontendURL, err = url.Parse(frontendURL)
+		if err != nil {
+			log.Fatalf("Error parsing OIDC_FRONTEND_URL: %s", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		apiCfg.oidc, err = oidc.Discover(ctx, oidc.Config{
+			Issuer:       issuer,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  redirectURL,
+		})
+		cancel()
+		if err != nil {
+			log.Fatalf("Error discovering OIDC provider: %s", err)
+		}
+	}
+
+	oauthServer := oauth.NewServer(oauthStore{db: dbQueries}, oauthProvider{cfg: &apiCfg})
+
 
 
 	mux := http.NewServeMux()
 	mux.Handle("/app/", apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot)))))
+	mux.Handle("/media/", http.StripPrefix("/media", http.FileServer(filesOnly{fs: http.Dir(mediaRoot)})))
 	mux.HandleFunc("GET /api/healthz", handlerReadiness)
 	// mux.HandleFunc("POST /api/validate_chirp", handlerChirpsValidate)
 	mux.HandleFunc("POST /api/users", apiCfg.handlerUsers)
-	mux.HandleFunc("POST /api/chirps",apiCfg.handlerChirps)
-	mux.HandleFunc("GET /api/chirps", apiCfg.handlerChirpsRetrieve)
-	mux.HandleFunc("GET /api/chirps/{chirpID}",apiCfg.handlerChirpRetrieve)
+	mux.HandleFunc("POST /api/chirps",apiCfg.requireScope(auth.ScopeChirpsWrite, apiCfg.handlerChirps))
+	mux.HandleFunc("GET /api/chirps", apiCfg.optionalAuth(apiCfg.handlerChirpsRetrieve))
+	mux.HandleFunc("POST /api/media", apiCfg.requireScope(auth.ScopeChirpsWrite, apiCfg.handlerMediaUpload))
+	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.optionalAuth(apiCfg.handlerChirpRetrieve))
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.requireScope(auth.ScopeChirpsWrite, apiCfg.handlerChirpUpdate))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", apiCfg.handlerChirpRevisions)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", apiCfg.optionalAuth(apiCfg.handlerChirpThread))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/like", apiCfg.requireScope(auth.ScopeChirpsWrite, apiCfg.handlerChirpLike))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/like", apiCfg.requireScope(auth.ScopeChirpsWrite, apiCfg.handlerChirpUnlike))
+	mux.HandleFunc("GET /api/users/{userID}/likes", apiCfg.optionalAuth(apiCfg.handlerUserLikes))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/rechirp", apiCfg.requireScope(auth.ScopeChirpsWrite, apiCfg.handlerRechirp))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirp", apiCfg.requireScope(auth.ScopeChirpsWrite, apiCfg.handlerUnrechirp))
 
 	mux.HandleFunc("POST /api/login", apiCfg.handlerLogin)
+	mux.HandleFunc("POST /api/login/2fa", apiCfg.handlerLoginTwoFactor)
+	if apiCfg.oidc != nil {
+		mux.HandleFunc("GET /api/login/oidc", apiCfg.handlerOIDCLogin)
+		mux.HandleFunc("GET /api/login/oidc/callback", apiCfg.handlerOIDCCallback)
+		mux.HandleFunc("POST /api/login/oidc/token", apiCfg.handlerOIDCToken)
+	}
 	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh )
 	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke )
-	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUsers )
-
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerChirpsDelete)
-
-	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerPolkaMembership)
+	mux.HandleFunc("PUT /api/users", apiCfg.requireScope(auth.ScopeProfileWrite, apiCfg.handlerUpdateUsers))
+	mux.HandleFunc("PATCH /api/users", apiCfg.requireScope(auth.ScopeProfileWrite, apiCfg.handlerUpdateUsers))
+	mux.HandleFunc("GET /api/users/{handleOrID}", apiCfg.handlerUserProfile)
+	mux.HandleFunc("DELETE /api/users/me", apiCfg.requireScope(auth.ScopeAccount, apiCfg.handlerUserDelete))
+	mux.HandleFunc("GET /api/users/me/export", apiCfg.requireScope(auth.ScopeAccount, apiCfg.handlerUserExport))
+	mux.HandleFunc("GET /api/users/me/export/{exportID}", apiCfg.requireScope(auth.ScopeAccount, apiCfg.handlerUserExportDownload))
+	mux.HandleFunc("GET /api/users/me/subscription", apiCfg.requireScope(auth.ScopeAccount, apiCfg.handlerSubscription))
+	mux.HandleFunc("POST /api/verify-email", apiCfg.handlerVerifyEmail)
+	mux.HandleFunc("POST /api/verify-email/resend", apiCfg.requireScope(auth.ScopeAccount, apiCfg.handlerVerifyEmailResend))
+	mux.HandleFunc("POST /api/password-reset", apiCfg.handlerPasswordResetRequest)
+	mux.HandleFunc("POST /api/password-reset/confirm", apiCfg.handlerPasswordResetConfirm)
+	mux.HandleFunc("POST /api/2fa/enroll", apiCfg.requireScope(auth.ScopeAccount, apiCfg.handlerTwoFactorEnroll))
+	mux.HandleFunc("POST /api/2fa/confirm", apiCfg.requireScope(auth.ScopeAccount, apiCfg.handlerTwoFactorConfirm))
+	mux.HandleFunc("DELETE /api/2fa", apiCfg.requireScope(auth.ScopeAccount, apiCfg.handlerTwoFactorDisable))
+
+	mux.HandleFunc("POST /api/users/{userID}/follow", apiCfg.requireScope(auth.ScopeFollowsWrite, apiCfg.handlerFollow))
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.requireScope(auth.ScopeFollowsWrite, apiCfg.handlerUnfollow))
+	mux.HandleFunc("GET /api/users/{userID}/followers", apiCfg.handlerFollowers)
+	mux.HandleFunc("GET /api/users/{userID}/following", apiCfg.handlerFollowing)
+	mux.HandleFunc("GET /api/timeline", apiCfg.requireScope(auth.ScopeChirpsRead, apiCfg.handlerTimeline))
+	mux.HandleFunc("GET /api/search", apiCfg.optionalAuth(apiCfg.handlerSearch))
+	mux.HandleFunc("GET /api/hashtags/trending", apiCfg.handlerTrendingHashtags)
+	mux.HandleFunc("GET /api/hashtags/{tag}/chirps", apiCfg.optionalAuth(apiCfg.handlerHashtagChirps))
+
+	mux.HandleFunc("GET /api/sessions", apiCfg.requireScope(auth.ScopeAccount, apiCfg.handlerSessionsList))
+	mux.HandleFunc("DELETE /api/sessions", apiCfg.requireScope(auth.ScopeAccount, apiCfg.handlerSessionsDeleteAll))
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", apiCfg.requireScope(auth.ScopeAccount, apiCfg.handlerSessionDelete))
+
+	mux.HandleFunc("POST /api/keys", apiCfg.requireScope(auth.ScopeAccount, apiCfg.handlerAPIKeysCreate))
+	mux.HandleFunc("GET /api/keys", apiCfg.requireScope(auth.ScopeAccount, apiCfg.handlerAPIKeysList))
+	mux.HandleFunc("DELETE /api/keys/{keyID}", apiCfg.requireScope(auth.ScopeAccount, apiCfg.handlerAPIKeyDelete))
+
+	mux.HandleFunc("POST /api/oauth/clients", apiCfg.requireScope(auth.ScopeAccount, apiCfg.handlerOAuthClientsCreate))
+	mux.HandleFunc("GET /api/oauth/clients", apiCfg.requireScope(auth.ScopeAccount, apiCfg.handlerOAuthClientsList))
+	mux.HandleFunc("DELETE /api/oauth/clients/{clientID}", apiCfg.requireScope(auth.ScopeAccount, apiCfg.handlerOAuthClientDelete))
+	mux.HandleFunc("GET /oauth/authorize", oauthServer.HandleAuthorize)
+	mux.HandleFunc("POST /oauth/authorize", oauthServer.HandleAuthorize)
+	mux.HandleFunc("POST /oauth/token", oauthServer.HandleToken)
+
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.requireScope(auth.ScopeChirpsWrite, apiCfg.handlerChirpsDelete))
+
+	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerPolkaWebhook)
 	
+	mux.HandleFunc("GET /.well-known/jwks.json", apiCfg.handlerJWKS)
+
 	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerMetrics)
 	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
 
+	mux.HandleFunc("POST /admin/users/{userID}/unlock", apiCfg.handlerAdminUnlockUser)
+
+	mux.HandleFunc("GET /admin/webhooks", apiCfg.handlerWebhookEventsList)
+	mux.HandleFunc("POST /admin/webhooks/{eventID}/replay", apiCfg.handlerWebhookEventReplay)
+
+	mux.HandleFunc("GET /admin/moderation/words", apiCfg.handlerModerationWordsList)
+	mux.HandleFunc("PUT /admin/moderation/words/{word}", apiCfg.handlerModerationWordPut)
+	mux.HandleFunc("DELETE /admin/moderation/words/{word}", apiCfg.handlerModerationWordDelete)
+	mux.HandleFunc("GET /admin/moderation/flagged", apiCfg.handlerModerationFlagged)
+	mux.HandleFunc("POST /admin/moderation/flagged/{chirpID}/review", apiCfg.handlerModerationReview)
+
 	srv := &http.Server{
 		Addr:    ":" + port,
 		Handler: mux,
@@ -156,125 +468,222 @@ func (cfg *apiConfig) handlerUsers(w http.ResponseWriter, r *http.Request)  {
 	}
 
 
-	respondWithJSON(w, http.StatusCreated, User{
-		ID: user.ID,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-		Email: user.Email,
-		IsChirpyRed: user.IsChirpyRed.Bool,
+	// The account is usable without verifying, so a mail failure isn't
+	// worth failing the signup over; they can ask for another email.
+	if err := cfg.sendVerificationEmail(r.Context(), user); err != nil {
+		log.Printf("Error sending verification email: %s", err)
+	}
+
+	created, err := cfg.userFromDB(r.Context(), user)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create user", err)
+		return
+	}
 
-	} )
+	respondWithJSON(w, http.StatusCreated, created)
 
 }
 
 func (cfg *apiConfig) handlerChirps(w http.ResponseWriter, r *http.Request)  {
 	type parameters struct {
-		Body string `json:"body"`
+		Body          string     `json:"body"`
+		InReplyToId   *uuid.UUID `json:"in_reply_to_id"`
+		QuotedChirpId *uuid.UUID `json:"quoted_chirp_id"`
+		MediaIds      []uuid.UUID `json:"media_ids"`
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
-		return
-	}
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+	userID := principalFrom(r.Context()).UserID
+	if !cfg.requireVerified(w, r, userID, actionChirp) {
 		return
 	}
 
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err = decoder.Decode(&params)
+	err := decoder.Decode(&params)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
 		return
 	}
 
-	cleaned, err := validateChirp(params.Body)
+	outcome, err := cfg.validateChirp(params.Body)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error(), err)
 		return
 	}
+	if len(params.MediaIds) > maxChirpMedia {
+		respondWithError(w, http.StatusBadRequest, errTooManyMedia.Error(), nil)
+		return
+	}
 
-	chirp, err := cfg.db.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   cleaned,
-		UserID: userID,
-	})
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
 		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	inReplyTo := uuid.NullUUID{}
+	if params.InReplyToId != nil {
+		parent, err := qtx.GetChirp(r.Context(), *params.InReplyToId)
+		// Replies to a rechirp belong in the original chirp's thread.
+		if err == nil && parent.RechirpOfID.Valid {
+			parent, err = qtx.GetChirp(r.Context(), parent.RechirpOfID.UUID)
+		}
+		// The parent is locked until the reply is counted, so it can't be
+		// deleted as if it had no replies in the meantime.
+		if err == nil {
+			parent, err = qtx.GetChirpForUpdate(r.Context(), parent.ID)
+		}
+		if err != nil || parent.DeletedAt.Valid {
+			respondWithError(w, http.StatusNotFound, "Chirp being replied to not found", err)
+			return
+		}
+		inReplyTo = uuid.NullUUID{UUID: parent.ID, Valid: true}
+	}
 
-	respondWithJSON(w, http.StatusCreated, Chirp{
-		Id:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserId:    chirp.UserID,
+	quoted := uuid.NullUUID{}
+	if params.QuotedChirpId != nil {
+		quotedChirp, err := qtx.GetChirp(r.Context(), *params.QuotedChirpId)
+		if err != nil || quotedChirp.DeletedAt.Valid {
+			respondWithError(w, http.StatusNotFound, "Quoted chirp not found", err)
+			return
+		}
+		quoted = uuid.NullUUID{UUID: quotedChirp.ID, Valid: true}
+		// Quoting a rechirp quotes the chirp it reposts.
+		if quotedChirp.RechirpOfID.Valid {
+			quoted = quotedChirp.RechirpOfID
+		}
+	}
+
+	chirp, err := qtx.CreateChirp(r.Context(), database.CreateChirpParams{
+		Body:          outcome.Body,
+		UserID:        uuid.NullUUID{UUID: userID, Valid: true},
+		InReplyToID:   inReplyTo,
+		QuotedChirpID: quoted,
 	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+		return
+	}
+
+	if err := saveChirpEntities(r.Context(), qtx, chirp); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+		return
+	}
+
+	err = attachChirpMedia(r, qtx, chirp, params.MediaIds)
+	if errors.Is(err, errTooManyMedia) || errors.Is(err, errInvalidMedia) {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+		return
+	}
+
+	if err := recordModeration(r.Context(), qtx, chirp.ID, outcome); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+		return
+	}
+
+	if inReplyTo.Valid {
+		if err := qtx.IncrementReplyCount(r.Context(), inReplyTo.UUID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+		return
+	}
+
+	created := chirpFromDB(chirp)
+	if err := cfg.decorateChirps(r, []*Chirp{&created}); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, created)
 }
-func validateChirp(body string) (string, error) {
+// validateChirp checks a chirp body's length and runs it through the
+// moderation pipeline. The returned outcome holds the body to store.
+func (cfg *apiConfig) validateChirp(body string) (moderation.Outcome, error) {
 	const maxChirpLength = 140
 	if len(body) > maxChirpLength {
-		return "", errors.New("Chirp is too long")
+		return moderation.Outcome{}, errors.New("Chirp is too long")
 	}
 
-	badWords := map[string]struct{}{
-		"kerfuffle": {},
-		"sharbert":  {},
-		"fornax":    {},
-	}
-	cleaned := getCleanedBody(body, badWords)
-	return cleaned, nil
+	return cfg.moderation.Run(body)
 }
 
-func getCleanedBody(body string, badWords map[string]struct{}) string {
-	words := strings.Split(body, " ")
-	for i, word := range words {
-		loweredWord := strings.ToLower(word)
-		if _, ok := badWords[loweredWord]; ok {
-			words[i] = "****"
+func (cfg *apiConfig) handlerChirpsRetrieve(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	authorID := uuid.NullUUID{}
+	if s := query.Get("author_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author_id", err)
+			return
 		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
 	}
-	cleaned := strings.Join(words, " ")
-	return cleaned
-}
 
-func (cfg *apiConfig) handlerChirpsRetrieve(w http.ResponseWriter, r *http.Request) {
-	s := r.URL.Query().Get("author_id")
-	sortChoice := r.URL.Query().Get("sort")
-	var authorId uuid.UUID
-	authorId, err := uuid.Parse(s)
-	if err != nil{
-		authorId = uuid.Nil
+	sortChoice := query.Get("sort")
+	if sortChoice != "" && sortChoice != "asc" && sortChoice != "desc" {
+		respondWithError(w, http.StatusBadRequest, "sort must be asc or desc", nil)
+		return
 	}
-	
 
-	dbChirps, err := cfg.db.GetChirps(r.Context())
+	limit, cursor, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	cursorCreatedAt, cursorID := cursorArgs(cursor)
+
+	// Paging backwards through an ascending feed is the same walk as paging
+	// forwards through a descending one, so two queries cover every case.
+	descending := sortChoice == "desc"
+	backward := cursor != nil && cursor.Direction == cursorPrev
+	var dbChirps []database.Chirp
+	if descending != backward {
+		dbChirps, err = cfg.db.ListChirpsBefore(r.Context(), database.ListChirpsBeforeParams{
+			AuthorID:        authorID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       int32(limit + 1),
+		})
+	} else {
+		dbChirps, err = cfg.db.ListChirpsAfter(r.Context(), database.ListChirpsAfterParams{
+			AuthorID:        authorID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       int32(limit + 1),
+		})
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
 		return
 	}
 
-	chirps := []Chirp{}
-	for _, dbChirp := range dbChirps {
-		if authorId == dbChirp.UserID || authorId == uuid.Nil {
-			chirps = append(chirps, Chirp{
-				Id:        dbChirp.ID,
-				CreatedAt: dbChirp.CreatedAt,
-				UpdatedAt: dbChirp.UpdatedAt,
-				UserId:    dbChirp.UserID,
-				Body:      dbChirp.Body,
-			})
-		} 
-	}
+	page, next, prev := paginate(dbChirps, limit, cursor, func(c database.Chirp) (time.Time, uuid.UUID) {
+		return c.CreatedAt, c.ID
+	})
 
-	if sortChoice == "desc" {
-		sort.Slice(chirps, func(i, j int) bool { return chirps[i].CreatedAt.After(chirps[j].CreatedAt)})
+	chirps := chirpsFromDB(page)
+	if err := cfg.decorateChirps(r, chirpRefs(chirps)); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
+		return
 	}
 
-	respondWithJSON(w, http.StatusOK, chirps)
+	respondWithJSON(w, http.StatusOK, chirpPage{
+		Chirps:     chirps,
+		NextCursor: next,
+		PrevCursor: prev,
+	})
 }
 
 
@@ -297,14 +706,23 @@ func (cfg  *apiConfig) handlerChirpRetrieve(w http.ResponseWriter, r *http.Reque
 		respondWithError(w, http.StatusNotFound, "Not Found", err)
 		return
 	}
+	if dbChirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Not Found", nil)
+		return
+	}
 
-	respondWithJSON(w, http.StatusOK, Chirp {
-		Id: dbChirp.ID,
-		CreatedAt: dbChirp.CreatedAt,
-		UpdatedAt: dbChirp.UpdatedAt,
-		Body: dbChirp.Body,
-		UserId: dbChirp.UserID,
-	})
+	chirp := chirpFromDB(dbChirp)
+	if err := cfg.decorateChirps(r, []*Chirp{&chirp}); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirp", err)
+		return
+	}
+	// Decorating hides chirps by deleted accounts.
+	if chirp.Deleted {
+		respondWithError(w, http.StatusNotFound, "Not Found", nil)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirp)
 
 }
 
@@ -313,11 +731,6 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request)  {
 		Password         string `json:"password"`
 		Email            string `json:"email"`
 	}
-	type response struct {
-		User
-		Token        string `json:"token"`
-		RefreshToken string `json:"refresh_token"`
-	}
 
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
@@ -327,62 +740,65 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request)  {
 		return
 	}
 
-	user, err := cfg.db.GetUser(r.Context(), params.Email)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
+	if !cfg.checkLoginLockout(w, r, params.Email) {
 		return
 	}
 
-	err = auth.CheckPasswordHash(params.Password, user.HashedPassword)
-	if err != nil {
+	user, err := cfg.checkPassword(r, params.Email, params.Password)
+	if errors.Is(err, errIncorrectPassword) {
 		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
 		return
 	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+		return
+	}
 
-	expirationTime := time.Hour
+	if user.TotpEnabledAt.Valid {
+		cfg.respondWithTwoFactorChallenge(w, user)
+		return
+	}
+	cfg.completeLogin(w, r, user)
+}
 
-	accessToken, err := auth.MakeJWT(
-		user.ID,
-		cfg.jwtSecret,
-		expirationTime,
-	)
+// completeLogin starts a new session for a user who has proved who they
+// are and responds with its tokens.
+func (cfg *apiConfig) completeLogin(w http.ResponseWriter, r *http.Request, user database.User) {
+	type response struct {
+		User
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
 
-	
-	
+	_, err := cfg.db.ClearLoginFailures(r.Context(), accountLoginKey(user.Email))
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create access JWT", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
 		return
 	}
-	refreshToken, err := auth.MakeRefreshToken()
+
+	// Logging in during the grace period cancels a pending deletion.
+	if user.DeletedAt.Valid {
+		user, err = cfg.db.RestoreUser(r.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't restore account", err)
+			return
+		}
+	}
+
+	accessToken, refreshToken, err := cfg.startSession(r, user.ID, sql.NullString{}, nil)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create refresh token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start session", err)
 		return
 	}
 
-	// refreshExpirationTime := (time.Hour * 24) * 60
-	// now := time.Now()
-	_, err = cfg.db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
-		Token: refreshToken,
-		ExpiresAt: time.Now().UTC().Add(time.Hour * 24 * 60),
-		RevokedAt: sql.NullTime{
-
-		},
-		UserID: user.ID,
-	})
-
+	loggedIn, err := cfg.userFromDB(r.Context(), user)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't save refresh token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve user", err)
 		return
 	}
 
 	respondWithJSON(w, http.StatusOK, response{
-		User: User{
-			ID:        user.ID,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-			Email:     user.Email,
-			IsChirpyRed: user.IsChirpyRed.Bool,
-		},
+		User: loggedIn,
 		Token: accessToken,
 		RefreshToken: refreshToken,
 	})
@@ -392,7 +808,8 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request)  {
 
 func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 	type response struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 
 	refreshToken, err := auth.GetBearerToken(r.Header)
@@ -401,24 +818,20 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := cfg.db.GetRefreshToken(r.Context(), refreshToken)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't get user for refresh token", err)
+	accessToken, newRefreshToken, err := cfg.rotateRefreshToken(r, refreshToken, sql.NullString{})
+	var refreshErr *refreshTokenError
+	if errors.As(err, &refreshErr) {
+		respondWithError(w, http.StatusUnauthorized, refreshErr.msg, refreshErr.err)
 		return
 	}
-
-	accessToken, err := auth.MakeJWT(
-		user.UserID,
-		cfg.jwtSecret,
-		time.Hour,
-	)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rotate refresh token", err)
 		return
 	}
 
 	respondWithJSON(w, http.StatusOK, response{
-		Token: accessToken,
+		Token:        accessToken,
+		RefreshToken: newRefreshToken,
 	})
 }
 
@@ -439,120 +852,227 @@ func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
 }
 
 func (cfg *apiConfig) handlerUpdateUsers(w http.ResponseWriter, r *http.Request)  {
-	accessToken, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't get token", err)
-		return
-	}
+	caller := principalFrom(r.Context())
+	userId := caller.UserID
+
+	// Every field is optional; anything left out keeps its current value.
+	// Changing the email or password needs the current password as well.
 	type Parameters struct {
-		Email string `json:"email"`
-		Password string `json:"password"`
+		Email *string `json:"email"`
+		Password *string `json:"password"`
+		CurrentPassword string `json:"current_password"`
+		profileUpdate
 	}
-	
+
 	decoder := json.NewDecoder(r.Body)
 	params := Parameters{}
-	err = decoder.Decode(&params)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't get token", err)
-		return
-	}
-	hashPassword, err := auth.HashPassword(params.Password)
+	err := decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't get token", err)
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
 		return
 	}
-	userId, err := auth.ValidateJWT(accessToken, cfg.jwtSecret)
 
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't get token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
 		return
 	}
-	newUser, err := cfg.db.UpdateUser(r.Context(), database.UpdateUserParams{
-		Email: params.Email,
-		HashedPassword: hashPassword,
-		ID: userId,
-	})
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
 
+	var pqErr *pq.Error
+	user, err := qtx.GetUserByID(r.Context(), userId)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't get token", err)
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find user", err)
 		return
 	}
-	respondWithJSON(w, http.StatusOK, User{
-		ID: newUser.ID,
-		CreatedAt: newUser.CreatedAt,
-		UpdatedAt: newUser.UpdatedAt,
-		Email: newUser.Email,
-		IsChirpyRed: newUser.IsChirpyRed.Bool,
 
-	})
+	emailChanged := false
+	if params.Email != nil || params.Password != nil {
+		// API keys can edit the profile but never take over the account.
+		if !auth.HasScope(caller.Scopes, auth.ScopeAccount) {
+			respondWithError(w, http.StatusForbidden, "Changing the email or password needs the account scope", nil)
+			return
+		}
+		if !cfg.checkCurrentPassword(w, r, user, params.CurrentPassword) {
+			return
+		}
 
-}
+		email := user.Email
+		if params.Email != nil {
+			emailChanged = *params.Email != user.Email
+			if *params.Email == "" {
+				respondWithError(w, http.StatusBadRequest, "Email can't be empty", nil)
+				return
+			}
+			email = *params.Email
+		}
+		hashPassword := user.HashedPassword
+		if params.Password != nil {
+			if *params.Password == "" {
+				respondWithError(w, http.StatusBadRequest, "Password can't be empty", nil)
+				return
+			}
+			hashPassword, err = auth.HashPassword(*params.Password)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
+				return
+			}
+		}
+		user, err = qtx.UpdateUser(r.Context(), database.UpdateUserParams{
+			Email: email,
+			HashedPassword: hashPassword,
+			ID: userId,
+		})
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			respondWithError(w, http.StatusConflict, "Email is already in use", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
+			return
+		}
 
-func (cfg *apiConfig) handlerChirpsDelete(w http.ResponseWriter, r *http.Request)  {
-	chirpID := r.PathValue("chirpID")
-	accessToken, err := auth.GetBearerToken(r.Header)
+		// Links already mailed to the old address mustn't work for the new one.
+		if emailChanged {
+			for _, purpose := range []string{tokenPurposeVerifyEmail, tokenPurposePasswordReset} {
+				err = qtx.InvalidateUserTokens(r.Context(), database.InvalidateUserTokensParams{
+					UserID:  userId,
+					Purpose: purpose,
+				})
+				if err != nil {
+					respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
+					return
+				}
+			}
+		}
+
+		// Anyone holding a refresh token from before the change is logged
+		// out, except the session making it.
+		if params.Password != nil {
+			err = qtx.RevokeOtherSessions(r.Context(), database.RevokeOtherSessionsParams{
+				UserID:    userId,
+				SessionID: caller.SessionID,
+			})
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+				return
+			}
+		}
+	}
 
+	profile, err := applyProfileUpdate(r.Context(), qtx, user, params.profileUpdate)
+	var invalid *profileError
+	if errors.Is(err, errHandleTaken) {
+		respondWithError(w, http.StatusConflict, err.Error(), err)
+		return
+	}
+	if errors.As(err, &invalid) {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
+		return
+	}
+	user, err = qtx.UpdateUserProfile(r.Context(), profile)
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		respondWithError(w, http.StatusConflict, errHandleTaken.Error(), err)
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "no access token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(accessToken, cfg.jwtSecret)
-	if err !=  nil {
-		respondWithError(w, http.StatusForbidden, "could not validate access token", err)
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
 		return
 	}
 
-	chirpId, err := uuid.Parse(chirpID)
-	if err != nil {
-		respondWithError(w, http.StatusForbidden, "could not convert chirp id to uuid", err)
-		return	
+	// Changing the email back and forth counts against the same limit as
+	// resending. Past it, the change still goes through without an email.
+	if emailChanged {
+		lockouts := verifyEmailLockouts(user.Email, clientIP(r))
+		until, err := cfg.lockedUntil(r.Context(), lockouts)
+		if err == nil && until.IsZero() {
+			err = cfg.recordFailures(r.Context(), lockouts, mailLimitReset)
+			if err == nil {
+				err = cfg.sendVerificationEmail(r.Context(), user)
+			}
+		}
+		if err != nil {
+			log.Printf("Error sending verification email: %s", err)
+		}
 	}
-	_, err = cfg.db.DeleteChirp(r.Context(), database.DeleteChirpParams{
-		ID: chirpId,
-		UserID: userID,
-	})
+
+	updated, err := cfg.userFromDB(r.Context(), user)
 	if err != nil {
-		respondWithError(w, http.StatusForbidden, "Chirp not found", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve user", err)
 		return
 	}
-	w.WriteHeader(http.StatusNoContent)
+	respondWithJSON(w, http.StatusOK, updated)
 
 }
 
-func (cfg *apiConfig) handlerPolkaMembership(w http.ResponseWriter, r *http.Request)  {
-	type Parameters struct {
-		Event string `json:"event"`
-		Data struct {
-			UserId uuid.UUID `json:"user_id"`
-		} `json:"data"`
+func (cfg *apiConfig) handlerChirpsDelete(w http.ResponseWriter, r *http.Request)  {
+	chirpID := r.PathValue("chirpID")
+	userID := principalFrom(r.Context()).UserID
+
+	chirpId, err := uuid.Parse(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusForbidden, "could not convert chirp id to uuid", err)
+		return	
 	}
-	apiKey, apiErr := auth.GetApiKey(r.Header)
-	if apiKey != os.Getenv("POLKA_API_KEY") {
-		respondWithError(w, http.StatusUnauthorized, "incorrect api key", apiErr)
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
 		return
 	}
-	decoder := json.NewDecoder(r.Body)
-	params := Parameters{}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
 
-	if err := decoder.Decode(&params); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Unable to decode", err)
+	// Locking the chirp keeps its reply count current until it's gone.
+	dbChirp, err := qtx.GetChirpForUpdate(r.Context(), chirpId)
+	if err != nil || dbChirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
 		return
 	}
-	if params.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
+	if dbChirp.UserID.UUID != userID {
+		respondWithError(w, http.StatusForbidden, "Chirp not found", nil)
 		return
 	}
 
-	_, err  := cfg.db.UpdateUserMembership(r.Context(), database.UpdateUserMembershipParams{
-		IsChirpyRed: sql.NullBool{Bool: true, Valid: true},
-		ID: params.Data.UserId,
-	})
+	// Chirps with replies are blanked rather than removed so their threads
+	// still hang together.
+	if dbChirp.ReplyCount > 0 {
+		_, err = qtx.TombstoneChirp(r.Context(), database.TombstoneChirpParams{
+			ID: chirpId,
+			UserID: uuid.NullUUID{UUID: userID, Valid: true},
+		})
+		if err == nil {
+			err = qtx.DeleteChirpEntities(r.Context(), chirpId)
+		}
+	} else {
+		_, err = qtx.DeleteChirp(r.Context(), database.DeleteChirpParams{
+			ID: chirpId,
+			UserID: uuid.NullUUID{UUID: userID, Valid: true},
+		})
+		if err == nil && dbChirp.InReplyToID.Valid {
+			err = qtx.DecrementReplyCount(r.Context(), dbChirp.InReplyToID.UUID)
+		}
+	}
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "user can not be found", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
 		return
 	}
 
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
+		return
+	}
 	w.WriteHeader(http.StatusNoContent)
+
 }
 
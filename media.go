@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/database"
+	"github.com/mrcordova/chirpy/internal/media"
+)
+
+const (
+	defaultMediaMaxBytes = 5 << 20
+	maxChirpMedia        = 4
+)
+
+var mediaExtensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// filesOnly serves files but not directories, so uploads can't be listed,
+// including ones that haven't been posted yet.
+type filesOnly struct {
+	fs http.FileSystem
+}
+
+func (f filesOnly) Open(name string) (http.File, error) {
+	file, err := f.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		file.Close()
+		return nil, fs.ErrNotExist
+	}
+	return file, nil
+}
+
+type Media struct {
+	ID           uuid.UUID `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	SizeBytes    int32     `json:"size_bytes"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+}
+
+func (cfg *apiConfig) mediaFromDB(m database.Medium) Media {
+	return Media{
+		ID:           m.ID,
+		CreatedAt:    m.CreatedAt,
+		URL:          cfg.storage.URL(m.StorageKey),
+		ThumbnailURL: cfg.storage.URL(m.ThumbnailKey),
+		ContentType:  m.ContentType,
+		SizeBytes:    m.SizeBytes,
+		Width:        m.Width,
+		Height:       m.Height,
+	}
+}
+
+func (cfg *apiConfig) handlerMediaUpload(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r.Context()).UserID
+	if !cfg.requireVerified(w, r, userID, actionMedia) {
+		return
+	}
+
+	// Leave some room over the file limit for the rest of the multipart body.
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.mediaMaxBytes+1<<20)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "File is too large", err)
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Couldn't find file in form field \"file\"", err)
+		return
+	}
+	defer file.Close()
+
+	data, err := media.ReadAll(file, cfg.mediaMaxBytes)
+	if err != nil {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "File is too large", err)
+		return
+	}
+
+	processed, err := media.Process(data)
+	if errors.Is(err, media.ErrUnsupportedType) {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Only JPEG, PNG and GIF images are allowed", err)
+		return
+	}
+	if errors.Is(err, media.ErrTooLarge) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Image dimensions are too large", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't process image", err)
+		return
+	}
+
+	id := uuid.New()
+	key := id.String() + "." + mediaExtensions[processed.ContentType]
+	thumbKey := id.String() + "_thumb.jpg"
+	if err := cfg.storage.Put(r.Context(), key, bytes.NewReader(processed.Data)); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't store file", err)
+		return
+	}
+	if err := cfg.storage.Put(r.Context(), thumbKey, bytes.NewReader(processed.Thumbnail)); err != nil {
+		cfg.storage.Delete(r.Context(), key)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't store file", err)
+		return
+	}
+
+	dbMedia, err := cfg.db.CreateMedia(r.Context(), database.CreateMediaParams{
+		ID:           id,
+		UserID:       userID,
+		StorageKey:   key,
+		ThumbnailKey: thumbKey,
+		ContentType:  processed.ContentType,
+		SizeBytes:    int32(len(processed.Data)),
+		Width:        int32(processed.Width),
+		Height:       int32(processed.Height),
+	})
+	if err != nil {
+		cfg.storage.Delete(r.Context(), key)
+		cfg.storage.Delete(r.Context(), thumbKey)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save media", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, cfg.mediaFromDB(dbMedia))
+}
+
+// attachChirpMedia links uploads to a new chirp in the order given. Each
+// upload must belong to the chirp's author and not already be attached to
+// another chirp.
+func attachChirpMedia(r *http.Request, db *database.Queries, chirp database.Chirp, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if len(ids) > maxChirpMedia {
+		return errTooManyMedia
+	}
+	seen := make(map[uuid.UUID]struct{}, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			return errInvalidMedia
+		}
+		seen[id] = struct{}{}
+	}
+
+	available, err := db.GetUnattachedMedia(r.Context(), database.GetUnattachedMediaParams{
+		UserID: chirp.UserID.UUID,
+		Ids:    ids,
+	})
+	if err != nil {
+		return err
+	}
+	if len(available) != len(ids) {
+		return errInvalidMedia
+	}
+
+	for i, id := range ids {
+		err := db.AttachChirpMedia(r.Context(), database.AttachChirpMediaParams{
+			ChirpID:  chirp.ID,
+			MediaID:  id,
+			Position: int32(i),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	errTooManyMedia = errors.New("A chirp can have at most 4 attachments")
+	errInvalidMedia = errors.New("Media not found or already attached")
+)
+
+// fillChirpMedia loads the attachments for a set of chirps.
+func (cfg *apiConfig) fillChirpMedia(r *http.Request, chirps []*Chirp) error {
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		if !chirp.Deleted {
+			ids = append(ids, chirp.Id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := cfg.db.ListChirpMedia(r.Context(), ids)
+	if err != nil {
+		return err
+	}
+	byChirp := make(map[uuid.UUID][]Media, len(chirps))
+	for _, row := range rows {
+		byChirp[row.ChirpID] = append(byChirp[row.ChirpID], cfg.mediaFromDB(row.Medium))
+	}
+	for _, chirp := range chirps {
+		if m, ok := byChirp[chirp.Id]; ok {
+			chirp.Media = m
+		}
+	}
+	return nil
+}
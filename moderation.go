@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/database"
+	"github.com/mrcordova/chirpy/internal/moderation"
+)
+
+const maxFlaggedChirps = 100
+
+type ModerationWord struct {
+	Word      string    `json:"word"`
+	Action    string    `json:"action"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// loadModerationWords fills the word filter from MODERATION_WORDLIST_FILE if
+// it is set, and from the moderation_words table otherwise.
+func (cfg *apiConfig) loadModerationWords(ctx context.Context) error {
+	if cfg.wordlistFile != "" {
+		words, err := moderation.LoadWordsFile(cfg.wordlistFile)
+		if err != nil {
+			return err
+		}
+		cfg.wordFilter.SetWords(words)
+		return nil
+	}
+
+	dbWords, err := cfg.db.ListModerationWords(ctx)
+	if err != nil {
+		return err
+	}
+	words := make([]moderation.Word, 0, len(dbWords))
+	for _, dbWord := range dbWords {
+		action, err := moderation.ParseAction(dbWord.Action)
+		if err != nil {
+			return err
+		}
+		words = append(words, moderation.Word{Word: dbWord.Word, Action: action})
+	}
+	cfg.wordFilter.SetWords(words)
+	return nil
+}
+
+// recordModeration stores what the pipeline decided about a new chirp.
+func recordModeration(ctx context.Context, db *database.Queries, chirpID uuid.UUID, outcome moderation.Outcome) error {
+	verdicts, err := json.Marshal(outcome.Verdicts)
+	if err != nil {
+		return err
+	}
+	return db.UpsertChirpModeration(ctx, database.UpsertChirpModerationParams{
+		ChirpID:  chirpID,
+		Action:   outcome.Action.String(),
+		Verdicts: verdicts,
+	})
+}
+
+func (cfg *apiConfig) handlerModerationWordsList(w http.ResponseWriter, r *http.Request) {
+	if !cfg.requireAdmin(w, r) {
+		return
+	}
+
+	dbWords, err := cfg.db.ListModerationWords(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve words", err)
+		return
+	}
+
+	words := make([]ModerationWord, 0, len(dbWords))
+	for _, dbWord := range dbWords {
+		words = append(words, ModerationWord{
+			Word:      dbWord.Word,
+			Action:    dbWord.Action,
+			CreatedAt: dbWord.CreatedAt,
+			UpdatedAt: dbWord.UpdatedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, words)
+}
+
+func (cfg *apiConfig) handlerModerationWordPut(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Action string `json:"action"`
+	}
+	if !cfg.requireAdmin(w, r) {
+		return
+	}
+	if cfg.wordlistFile != "" {
+		respondWithError(w, http.StatusConflict, "Wordlist is loaded from a file", nil)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	action, err := moderation.ParseAction(params.Action)
+	if err != nil || action == moderation.ActionAllow {
+		respondWithError(w, http.StatusBadRequest, "action must be censor, flag or reject", err)
+		return
+	}
+
+	dbWord, err := cfg.db.UpsertModerationWord(r.Context(), database.UpsertModerationWordParams{
+		Word:   r.PathValue("word"),
+		Action: action.String(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save word", err)
+		return
+	}
+	if err := cfg.loadModerationWords(r.Context()); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reload words", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, ModerationWord{
+		Word:      dbWord.Word,
+		Action:    dbWord.Action,
+		CreatedAt: dbWord.CreatedAt,
+		UpdatedAt: dbWord.UpdatedAt,
+	})
+}
+
+func (cfg *apiConfig) handlerModerationWordDelete(w http.ResponseWriter, r *http.Request) {
+	if !cfg.requireAdmin(w, r) {
+		return
+	}
+	if cfg.wordlistFile != "" {
+		respondWithError(w, http.StatusConflict, "Wordlist is loaded from a file", nil)
+		return
+	}
+
+	removed, err := cfg.db.DeleteModerationWord(r.Context(), r.PathValue("word"))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete word", err)
+		return
+	}
+	if removed == 0 {
+		respondWithError(w, http.StatusNotFound, "Word not found", nil)
+		return
+	}
+	if err := cfg.loadModerationWords(r.Context()); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reload words", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerModerationFlagged(w http.ResponseWriter, r *http.Request) {
+	type flaggedChirp struct {
+		Chirp    Chirp                `json:"chirp"`
+		Verdicts []moderation.Verdict `json:"verdicts"`
+	}
+	if !cfg.requireAdmin(w, r) {
+		return
+	}
+
+	rows, err := cfg.db.ListFlaggedChirps(r.Context(), maxFlaggedChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve flagged chirps", err)
+		return
+	}
+
+	flagged := make([]flaggedChirp, 0, len(rows))
+	for _, row := range rows {
+		verdicts := []moderation.Verdict{}
+		if err := json.Unmarshal(row.Verdicts, &verdicts); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't decode verdicts", err)
+			return
+		}
+		flagged = append(flagged, flaggedChirp{
+			Chirp:    chirpFromDB(row.Chirp),
+			Verdicts: verdicts,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, flagged)
+}
+
+func (cfg *apiConfig) handlerModerationReview(w http.ResponseWriter, r *http.Request) {
+	if !cfg.requireAdmin(w, r) {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	reviewed, err := cfg.db.ReviewChirpModeration(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't review chirp", err)
+		return
+	}
+	if reviewed == 0 {
+		respondWithError(w, http.StatusNotFound, "Chirp not awaiting review", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/auth"
+	"github.com/mrcordova/chirpy/internal/database"
+	"github.com/mrcordova/chirpy/internal/oauth"
+)
+
+// oauthStore keeps OAuth clients and authorization codes in the database.
+type oauthStore struct {
+	db *database.Queries
+}
+
+func (s oauthStore) GetClient(ctx context.Context, id string) (oauth.Client, error) {
+	client, err := s.db.GetOAuthClient(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return oauth.Client{}, oauth.ErrNotFound
+	}
+	if err != nil {
+		return oauth.Client{}, err
+	}
+	return oauth.Client{
+		ID:           client.ID,
+		Name:         client.Name,
+		SecretHash:   client.SecretHash.String,
+		RedirectURIs: client.RedirectUris,
+		Scopes:       client.Scopes,
+	}, nil
+}
+
+func (s oauthStore) CreateCode(ctx context.Context, codeHash string, code oauth.Code) error {
+	return s.db.CreateOAuthCode(ctx, database.CreateOAuthCodeParams{
+		CodeHash:      codeHash,
+		ExpiresAt:     code.ExpiresAt,
+		ClientID:      code.ClientID,
+		UserID:        code.UserID,
+		RedirectUri:   code.RedirectURI,
+		Scopes:        code.Scopes,
+		CodeChallenge: code.CodeChallenge,
+	})
+}
+
+func (s oauthStore) UseCode(ctx context.Context, codeHash string) (oauth.Code, error) {
+	code, err := s.db.UseOAuthCode(ctx, codeHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return oauth.Code{}, oauth.ErrNotFound
+	}
+	if err != nil {
+		return oauth.Code{}, err
+	}
+	return oauth.Code{
+		ClientID:      code.ClientID,
+		UserID:        code.UserID,
+		RedirectURI:   code.RedirectUri,
+		Scopes:        code.Scopes,
+		CodeChallenge: code.CodeChallenge,
+		ExpiresAt:     code.ExpiresAt,
+	}, nil
+}
+
+// oauthProvider lets the OAuth server sign users in and start sessions the
+// same way the API's own login does.
+type oauthProvider struct {
+	cfg *apiConfig
+}
+
+// Authenticate applies the same lockout, password and 2FA checks as
+// POST /api/login, with the second factor typed on the consent page.
+func (p oauthProvider) Authenticate(r *http.Request, creds oauth.Credentials) (uuid.UUID, error) {
+	cfg := p.cfg
+
+	until, err := cfg.lockedUntil(r.Context(), loginLockouts(creds.Email, clientIP(r)))
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !until.IsZero() {
+		return uuid.Nil, &oauth.LoginError{Message: "Too many failed login attempts, try again later"}
+	}
+
+	user, err := cfg.checkPassword(r, creds.Email, creds.Password)
+	if errors.Is(err, errIncorrectPassword) {
+		return uuid.Nil, &oauth.LoginError{Message: "Incorrect email or password"}
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	// Restoring an account is left to the app itself, so a third party
+	// can't undo a deletion the user asked for.
+	if user.DeletedAt.Valid {
+		return uuid.Nil, &oauth.LoginError{Message: "This account is scheduled for deletion"}
+	}
+
+	if user.TotpEnabledAt.Valid {
+		if creds.OTP == "" {
+			return uuid.Nil, &oauth.LoginError{Message: "Enter your two-factor or recovery code"}
+		}
+		code, recoveryCode := creds.OTP, ""
+		if len(creds.OTP) != auth.TOTPDigits {
+			code, recoveryCode = "", creds.OTP
+		}
+		ok, err := cfg.checkSecondFactor(r.Context(), user, code, recoveryCode)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		if !ok {
+			if err := cfg.recordLoginFailure(r.Context(), user.Email, clientIP(r)); err != nil {
+				return uuid.Nil, err
+			}
+			return uuid.Nil, &oauth.LoginError{Message: "Invalid two-factor code"}
+		}
+	}
+
+	if _, err := cfg.db.ClearLoginFailures(r.Context(), accountLoginKey(user.Email)); err != nil {
+		return uuid.Nil, err
+	}
+	return user.ID, nil
+}
+
+func (p oauthProvider) IssueTokens(r *http.Request, userID uuid.UUID, clientID string, scopes []string) (oauth.Tokens, error) {
+	accessToken, refreshToken, err := p.cfg.startSession(r, userID, sql.NullString{String: clientID, Valid: true}, scopes)
+	if err != nil {
+		return oauth.Tokens{}, err
+	}
+	return oauth.Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    accessTokenDuration,
+		Scopes:       scopes,
+	}, nil
+}
+
+func (p oauthProvider) RefreshTokens(r *http.Request, refreshToken, clientID string) (oauth.Tokens, error) {
+	accessToken, newRefreshToken, err := p.cfg.rotateRefreshToken(r, refreshToken, sql.NullString{String: clientID, Valid: true})
+	var tokenErr *refreshTokenError
+	if errors.As(err, &tokenErr) {
+		return oauth.Tokens{}, fmt.Errorf("%s: %w", tokenErr.msg, oauth.ErrInvalidGrant)
+	}
+	if err != nil {
+		return oauth.Tokens{}, err
+	}
+
+	// The scopes carry over from the session, so read them back from the
+	// access token rather than looking the session up again.
+	token, err := auth.ParseAccessToken(accessToken, p.cfg.jwtKeys)
+	if err != nil {
+		return oauth.Tokens{}, err
+	}
+	return oauth.Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
+		ExpiresIn:    accessTokenDuration,
+		Scopes:       token.Scopes,
+	}, nil
+}
+
+func (cfg *apiConfig) removeExpiredOAuthCodes(ctx context.Context) {
+	if err := cfg.db.DeleteExpiredOAuthCodes(ctx, time.Now().UTC()); err != nil {
+		log.Printf("Error deleting expired authorization codes: %s", err)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mrcordova/chirpy/internal/auth"
+	"github.com/mrcordova/chirpy/internal/database"
+)
+
+const (
+	maxOAuthClientNameLength   = 50
+	maxOAuthClientRedirectURIs = 5
+)
+
+type OAuthClient struct {
+	ID           string    `json:"client_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Public       bool      `json:"public"`
+	// Secret is only ever sent in the response that creates the client.
+	Secret string `json:"client_secret,omitempty"`
+}
+
+func oauthClientFromDB(c database.OauthClient) OAuthClient {
+	return OAuthClient{
+		ID:           c.ID,
+		CreatedAt:    c.CreatedAt,
+		Name:         c.Name,
+		RedirectURIs: c.RedirectUris,
+		Scopes:       c.Scopes,
+		Public:       !c.SecretHash.Valid,
+	}
+}
+
+// validRedirectURI reports whether uri can receive authorization codes.
+// Codes must travel over HTTPS, except to a native app listening on the
+// loopback interface.
+func validRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" || u.Fragment != "" || u.User != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		if u.Hostname() == "localhost" {
+			return true
+		}
+		ip := net.ParseIP(u.Hostname())
+		return ip != nil && ip.IsLoopback()
+	}
+	return false
+}
+
+// handlerOAuthClientsCreate registers an app that can ask users for access
+// to their accounts. Public clients, which can't keep a secret, get none
+// and must rely on PKCE alone.
+func (cfg *apiConfig) handlerOAuthClientsCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		Public       bool     `json:"public"`
+	}
+
+	userID := principalFrom(r.Context()).UserID
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	if params.Name == "" || utf8.RuneCountInString(params.Name) > maxOAuthClientNameLength {
+		respondWithError(w, http.StatusBadRequest, "Name must be between 1 and 50 characters", nil)
+		return
+	}
+	if len(params.RedirectURIs) == 0 || len(params.RedirectURIs) > maxOAuthClientRedirectURIs {
+		respondWithError(w, http.StatusBadRequest, "A client needs between 1 and 5 redirect URIs", nil)
+		return
+	}
+	for _, uri := range params.RedirectURIs {
+		if !validRedirectURI(uri) {
+			respondWithError(w, http.StatusBadRequest, "Redirect URIs must be https, or http on a loopback address, without a fragment", nil)
+			return
+		}
+	}
+	scopes, err := auth.ValidateScopes(params.Scopes, auth.DelegableScopes)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if len(scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "A client needs at least one scope", nil)
+		return
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create client", err)
+		return
+	}
+	var secret string
+	var secretHash sql.NullString
+	if !params.Public {
+		secret, err = auth.MakeRefreshToken()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create client", err)
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+	}
+
+	dbClient, err := cfg.db.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		ID:           hex.EncodeToString(id),
+		UserID:       userID,
+		Name:         params.Name,
+		SecretHash:   secretHash,
+		RedirectUris: params.RedirectURIs,
+		Scopes:       scopes,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create client", err)
+		return
+	}
+
+	created := oauthClientFromDB(dbClient)
+	created.Secret = secret
+	respondWithJSON(w, http.StatusCreated, created)
+}
+
+func (cfg *apiConfig) handlerOAuthClientsList(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r.Context()).UserID
+
+	dbClients, err := cfg.db.ListOAuthClients(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve clients", err)
+		return
+	}
+
+	clients := make([]OAuthClient, 0, len(dbClients))
+	for _, dbClient := range dbClients {
+		clients = append(clients, oauthClientFromDB(dbClient))
+	}
+	respondWithJSON(w, http.StatusOK, clients)
+}
+
+// handlerOAuthClientDelete removes a client along with every session and
+// authorization code it holds.
+func (cfg *apiConfig) handlerOAuthClientDelete(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r.Context()).UserID
+
+	rows, err := cfg.db.DeleteOAuthClient(r.Context(), database.DeleteOAuthClientParams{
+		ID:     r.PathValue("clientID"),
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete client", err)
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, http.StatusNotFound, "Client not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
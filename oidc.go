@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mrcordova/chirpy/internal/auth"
+	"github.com/mrcordova/chirpy/internal/database"
+	"github.com/mrcordova/chirpy/internal/oauth"
+	"github.com/mrcordova/chirpy/internal/oidc"
+)
+
+const (
+	oidcStateCookie   = "chirpy_oidc_state"
+	oidcStateDuration = 10 * time.Minute
+	oidcCookiePath    = "/api/login/oidc"
+	// oidcLoginCodeDuration only has to cover the front end loading and
+	// posting the code straight back.
+	oidcLoginCodeDuration = time.Minute
+
+	tokenPurposeOIDCLogin = "oidc_login"
+)
+
+// identityError is an external identity that can't be signed in, as
+// opposed to a failure looking it up.
+type identityError struct {
+	msg string
+}
+
+func (e *identityError) Error() string {
+	return e.msg
+}
+
+// handlerOIDCLogin sends the browser to the identity provider. The state,
+// nonce and PKCE verifier ride along in a signed cookie, so only the
+// browser that started the login can finish it.
+func (cfg *apiConfig) handlerOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	var state auth.LoginState
+	for _, value := range []*string{&state.State, &state.Nonce, &state.CodeVerifier} {
+		random, err := auth.MakeRefreshToken()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't start login", err)
+			return
+		}
+		*value = random
+	}
+	cookie, err := auth.MakeLoginStateJWT(state, cfg.jwtKeys, oidcStateDuration)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start login", err)
+		return
+	}
+
+	cfg.setOIDCStateCookie(w, cookie, int(oidcStateDuration/time.Second))
+	http.Redirect(w, r, cfg.oidc.AuthCodeURL(state.State, state.Nonce, oauth.S256Challenge(state.CodeVerifier)), http.StatusFound)
+}
+
+func (cfg *apiConfig) setOIDCStateCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     oidcCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.appBaseURL, "https://"),
+		// Lax still sends the cookie on the provider's redirect back.
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// handlerOIDCCallback finishes a login the provider redirected back from.
+// Tokens never go in a URL or a page the browser renders, so it sends the
+// browser on to the front end with a short-lived, single-use code, which
+// the front end trades at POST /api/login/oidc/token. Failures go to the
+// same page with an error message instead.
+func (cfg *apiConfig) handlerOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	// The state is single use, whatever happens next.
+	cfg.setOIDCStateCookie(w, "", -1)
+
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		cfg.redirectOIDCError(w, r, "Identity provider didn't sign you in: "+errCode, nil)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		cfg.redirectOIDCError(w, r, "Login wasn't started in this browser or has expired", err)
+		return
+	}
+	state, err := auth.ValidateLoginStateJWT(cookie.Value, cfg.jwtKeys)
+	if err != nil {
+		cfg.redirectOIDCError(w, r, "Login wasn't started in this browser or has expired", err)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state.State)) != 1 {
+		cfg.redirectOIDCError(w, r, "Login state doesn't match", nil)
+		return
+	}
+
+	identity, err := cfg.oidc.Exchange(r.Context(), query.Get("code"), state.CodeVerifier, state.Nonce)
+	if errors.Is(err, oidc.ErrInvalidIDToken) {
+		cfg.redirectOIDCError(w, r, "Identity provider sent an invalid ID token", err)
+		return
+	}
+	if err != nil {
+		cfg.redirectOIDCError(w, r, "Couldn't sign in with identity provider", err)
+		return
+	}
+
+	user, err := cfg.userForIdentity(r.Context(), identity)
+	var idErr *identityError
+	if errors.As(err, &idErr) {
+		cfg.redirectOIDCError(w, r, idErr.msg, err)
+		return
+	}
+	if err != nil {
+		cfg.redirectOIDCError(w, r, "Couldn't log in", err)
+		return
+	}
+
+	code, err := issueUserToken(r.Context(), cfg.db, user.ID, tokenPurposeOIDCLogin, oidcLoginCodeDuration)
+	if err != nil {
+		cfg.redirectOIDCError(w, r, "Couldn't log in", err)
+		return
+	}
+	cfg.redirectOIDCFrontend(w, r, url.Values{"code": {code}})
+}
+
+// handlerOIDCToken trades the code from handlerOIDCCallback for the same
+// response POST /api/login gives.
+func (cfg *apiConfig) handlerOIDCToken(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	used, err := cfg.db.UseUserToken(r.Context(), database.UseUserTokenParams{
+		TokenHash: auth.HashToken(params.Code),
+		Purpose:   tokenPurposeOIDCLogin,
+		Now:       time.Now().UTC(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired code", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), used.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+		return
+	}
+
+	if user.TotpEnabledAt.Valid {
+		cfg.respondWithTwoFactorChallenge(w, user)
+		return
+	}
+	cfg.completeLogin(w, r, user)
+}
+
+func (cfg *apiConfig) redirectOIDCError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	if err != nil {
+		log.Println(err)
+	}
+	cfg.redirectOIDCFrontend(w, r, url.Values{"error": {msg}})
+}
+
+// redirectOIDCFrontend sends the browser to the front end's login page with
+// values added to its query string.
+func (cfg *apiConfig) redirectOIDCFrontend(w http.ResponseWriter, r *http.Request, values url.Values) {
+	target := *cfg.oidcFrontendURL
+	query := target.Query()
+	for key, vals := range values {
+		query[key] = vals
+	}
+	target.RawQuery = query.Encode()
+	// The code mustn't leak to other sites through the Referer header.
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// userForIdentity finds the user an external identity signs in as. An
+// identity seen before keeps its user. A new one is linked to the user
+// with the same email, ignoring case, or gets a new user, but only if the
+// provider has verified the email.
+func (cfg *apiConfig) userForIdentity(ctx context.Context, identity oidc.IDToken) (database.User, error) {
+	user, err := cfg.linkIdentity(ctx, identity)
+	// Another login with the same identity or email created it first, so
+	// this time it's found.
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return cfg.linkIdentity(ctx, identity)
+	}
+	return user, err
+}
+
+func (cfg *apiConfig) linkIdentity(ctx context.Context, identity oidc.IDToken) (database.User, error) {
+	linked, err := cfg.db.GetUserIdentity(ctx, database.GetUserIdentityParams{
+		Issuer:  identity.Issuer,
+		Subject: identity.Subject,
+	})
+	if err == nil {
+		return cfg.db.GetUserByID(ctx, linked.UserID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return database.User{}, &identityError{msg: "Identity provider didn't share a verified email address"}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.User{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	user, err := qtx.GetUserByEmailFold(ctx, identity.Email)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		user, err = qtx.CreateExternalUser(ctx, database.CreateExternalUserParams{
+			Email:       identity.Email,
+			DisplayName: truncateRunes(identity.Name, maxDisplayNameLength),
+		})
+		if err != nil {
+			return database.User{}, err
+		}
+	case err != nil:
+		return database.User{}, err
+	case !user.EmailVerifiedAt.Valid:
+		// Whoever signed up with this email never proved it was theirs, so
+		// handing them the provider's user could give away the account.
+		return database.User{}, &identityError{msg: "An account with this email hasn't verified it yet. Verify the email or reset the password, then try again"}
+	}
+
+	_, err = qtx.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+		Issuer:  identity.Issuer,
+		Subject: identity.Subject,
+		UserID:  user.ID,
+		Email:   identity.Email,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return database.User{}, err
+	}
+	return user, nil
+}
+
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
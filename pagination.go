@@ -0,0 +1,151 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+type cursorDirection string
+
+const (
+	cursorNext cursorDirection = "next"
+	cursorPrev cursorDirection = "prev"
+)
+
+// pageCursor marks a position in a list ordered by (created_at, id). It is
+// handed to clients as an opaque string and tells us which way to page.
+type pageCursor struct {
+	CreatedAt time.Time       `json:"t"`
+	ID        uuid.UUID       `json:"id"`
+	Direction cursorDirection `json:"d"`
+}
+
+func encodeCursor(c pageCursor) string {
+	dat, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(dat)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	dat, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, errors.New("invalid cursor")
+	}
+	c := pageCursor{}
+	if err := json.Unmarshal(dat, &c); err != nil {
+		return pageCursor{}, errors.New("invalid cursor")
+	}
+	if c.Direction != cursorNext && c.Direction != cursorPrev {
+		return pageCursor{}, errors.New("invalid cursor")
+	}
+	return c, nil
+}
+
+func parsePageLimit(s string) (int, error) {
+	if s == "" {
+		return defaultPageLimit, nil
+	}
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit < 1 {
+		return 0, errors.New("limit must be a positive integer")
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	return limit, nil
+}
+
+// parsePageParams reads the limit and cursor query parameters shared by every
+// paginated endpoint.
+func parsePageParams(r *http.Request) (int, *pageCursor, error) {
+	limit, err := parsePageLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		return 0, nil, err
+	}
+	s := r.URL.Query().Get("cursor")
+	if s == "" {
+		return limit, nil, nil
+	}
+	c, err := decodeCursor(s)
+	if err != nil {
+		return 0, nil, err
+	}
+	return limit, &c, nil
+}
+
+// cursorArgs converts a cursor into the nullable query arguments used by the
+// keyset queries; a nil cursor starts from the beginning.
+func cursorArgs(cursor *pageCursor) (sql.NullTime, uuid.NullUUID) {
+	if cursor == nil {
+		return sql.NullTime{}, uuid.NullUUID{}
+	}
+	return sql.NullTime{Time: cursor.CreatedAt, Valid: true}, uuid.NullUUID{UUID: cursor.ID, Valid: true}
+}
+
+// paginate trims rows fetched with limit+1 down to a page and works out the
+// cursors either side of it. Rows fetched for a prev cursor come back in the
+// opposite order and are reversed here.
+func paginate[T any](rows []T, limit int, cursor *pageCursor, key func(T) (time.Time, uuid.UUID)) (page []T, next, prev string) {
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+	backward := cursor != nil && cursor.Direction == cursorPrev
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+	if len(rows) == 0 {
+		return rows, "", ""
+	}
+
+	makeCursor := func(row T, dir cursorDirection) string {
+		createdAt, id := key(row)
+		return encodeCursor(pageCursor{CreatedAt: createdAt, ID: id, Direction: dir})
+	}
+	if (!backward && hasMore) || backward {
+		next = makeCursor(rows[len(rows)-1], cursorNext)
+	}
+	if (backward && hasMore) || (!backward && cursor != nil) {
+		prev = makeCursor(rows[0], cursorPrev)
+	}
+	return rows, next, prev
+}
+
+// offsetCursor is used where results are ordered by something other than
+// (created_at, id), such as search rank, and keyset paging doesn't apply.
+type offsetCursor struct {
+	Offset int `json:"o"`
+}
+
+func encodeOffsetCursor(offset int) string {
+	dat, _ := json.Marshal(offsetCursor{Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(dat)
+}
+
+func decodeOffsetCursor(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	dat, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+	c := offsetCursor{}
+	if err := json.Unmarshal(dat, &c); err != nil || c.Offset < 0 {
+		return 0, errors.New("invalid cursor")
+	}
+	return c.Offset, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/base64"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var testPageStart = time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+
+// testRowKey gives row n a created_at and id that sort in row order.
+func testRowKey(n int) (time.Time, uuid.UUID) {
+	return testPageStart.Add(time.Duration(n) * time.Minute), uuid.UUID{15: byte(n)}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, dir := range []cursorDirection{cursorNext, cursorPrev} {
+		createdAt, id := testRowKey(7)
+		want := pageCursor{CreatedAt: createdAt, ID: id, Direction: dir}
+		got, err := decodeCursor(encodeCursor(want))
+		if err != nil {
+			t.Fatalf("decodeCursor() error = %v", err)
+		}
+		if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID || got.Direction != want.Direction {
+			t.Errorf("decodeCursor() = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestDecodeCursorErrors(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"Not base64", "not a cursor!"},
+		{"Not JSON", encode("hello")},
+		{"No direction", encode(`{"t":"2024-01-02T03:04:05Z","id":"00000000-0000-0000-0000-000000000001"}`)},
+		{"Unknown direction", encode(`{"t":"2024-01-02T03:04:05Z","id":"00000000-0000-0000-0000-000000000001","d":"sideways"}`)},
+		{"Bad ID", encode(`{"t":"2024-01-02T03:04:05Z","id":"nope","d":"next"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if c, err := decodeCursor(tt.cursor); err == nil {
+				t.Errorf("decodeCursor() = %+v, want an error", c)
+			}
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	const limit = 3
+	cursorAt := func(row int, dir cursorDirection) *pageCursor {
+		createdAt, id := testRowKey(row)
+		return &pageCursor{CreatedAt: createdAt, ID: id, Direction: dir}
+	}
+
+	tests := []struct {
+		name   string
+		rows   []int
+		cursor *pageCursor
+		want   []int
+		// wantNext and wantPrev are the rows the cursors point at, or -1
+		// for no cursor.
+		wantNext int
+		wantPrev int
+	}{
+		{
+			name:     "First page with more",
+			rows:     []int{1, 2, 3, 4},
+			want:     []int{1, 2, 3},
+			wantNext: 3,
+			wantPrev: -1,
+		},
+		{
+			name:     "Only page",
+			rows:     []int{1, 2},
+			want:     []int{1, 2},
+			wantNext: -1,
+			wantPrev: -1,
+		},
+		{
+			name:     "Next page with more",
+			rows:     []int{4, 5, 6, 7},
+			cursor:   cursorAt(3, cursorNext),
+			want:     []int{4, 5, 6},
+			wantNext: 6,
+			wantPrev: 4,
+		},
+		{
+			name:     "Last page",
+			rows:     []int{7, 8},
+			cursor:   cursorAt(6, cursorNext),
+			want:     []int{7, 8},
+			wantNext: -1,
+			wantPrev: 7,
+		},
+		{
+			// Paging back fetches rows newest first.
+			name:     "Previous page with more",
+			rows:     []int{6, 5, 4, 3},
+			cursor:   cursorAt(7, cursorPrev),
+			want:     []int{4, 5, 6},
+			wantNext: 6,
+			wantPrev: 4,
+		},
+		{
+			name:     "Previous page reaching the start",
+			rows:     []int{2, 1},
+			cursor:   cursorAt(3, cursorPrev),
+			want:     []int{1, 2},
+			wantNext: 2,
+			wantPrev: -1,
+		},
+		{
+			name:     "Past the end",
+			rows:     nil,
+			cursor:   cursorAt(8, cursorNext),
+			want:     nil,
+			wantNext: -1,
+			wantPrev: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, next, prev := paginate(tt.rows, limit, tt.cursor, testRowKey)
+			if !slices.Equal(page, tt.want) {
+				t.Errorf("page = %v, want %v", page, tt.want)
+			}
+			checkCursor(t, "next", next, tt.wantNext, cursorNext)
+			checkCursor(t, "prev", prev, tt.wantPrev, cursorPrev)
+		})
+	}
+}
+
+func checkCursor(t *testing.T, name, cursor string, wantRow int, wantDir cursorDirection) {
+	t.Helper()
+	if wantRow < 0 {
+		if cursor != "" {
+			t.Errorf("%s cursor = %q, want none", name, cursor)
+		}
+		return
+	}
+	c, err := decodeCursor(cursor)
+	if err != nil {
+		t.Errorf("%s cursor %q: %v", name, cursor, err)
+		return
+	}
+	createdAt, id := testRowKey(wantRow)
+	if !c.CreatedAt.Equal(createdAt) || c.ID != id || c.Direction != wantDir {
+		t.Errorf("%s cursor = %+v, want row %d going %s", name, c, wantRow, wantDir)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/auth"
+	"github.com/mrcordova/chirpy/internal/database"
+)
+
+// principal is who a request acts for and what it's allowed to do.
+type principal struct {
+	UserID uuid.UUID
+	// SessionID is set for tokens from a login, and APIKeyID for API keys.
+	// ClientID is set when an OAuth client is acting for the user.
+	SessionID uuid.UUID
+	APIKeyID  uuid.UUID
+	ClientID  string
+	Scopes    []string
+}
+
+type principalKey struct{}
+
+// principalFrom returns the principal withAuth stored for the request. It's
+// the zero principal for anonymous requests to routes with optional auth.
+func principalFrom(ctx context.Context) principal {
+	p, _ := ctx.Value(principalKey{}).(principal)
+	return p
+}
+
+// invalidTokenError is a bearer token the client sent that can't be used,
+// as opposed to a failure checking it.
+type invalidTokenError struct {
+	msg string
+	err error
+}
+
+func (e *invalidTokenError) Error() string {
+	if e.err != nil {
+		return e.msg + ": " + e.err.Error()
+	}
+	return e.msg
+}
+
+func (e *invalidTokenError) Unwrap() error {
+	return e.err
+}
+
+// authenticate checks the request's bearer token, which may be an access
+// token or a personal API key. It returns auth.ErrNoAuthHeaderIncluded if
+// there's no token and *invalidTokenError if there's a bad one, including
+// one for an account that has been deleted.
+func (cfg *apiConfig) authenticate(r *http.Request) (principal, error) {
+	p, err := cfg.authenticateToken(r)
+	if err != nil {
+		return principal{}, err
+	}
+
+	// Access tokens outlive the sessions revoked when an account is
+	// deleted, so the account is checked on every request.
+	user, err := cfg.db.GetUserByID(r.Context(), p.UserID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && user.DeletedAt.Valid) {
+		return principal{}, &invalidTokenError{msg: "Account has been deleted", err: err}
+	}
+	if err != nil {
+		return principal{}, err
+	}
+	return p, nil
+}
+
+func (cfg *apiConfig) authenticateToken(r *http.Request) (principal, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if errors.Is(err, auth.ErrNoAuthHeaderIncluded) {
+		return principal{}, err
+	}
+	if err != nil {
+		return principal{}, &invalidTokenError{msg: "Authorization header must be a bearer token", err: err}
+	}
+	if auth.IsAPIKey(token) {
+		return cfg.authenticateAPIKey(r.Context(), token)
+	}
+
+	access, err := auth.ParseAccessToken(token, cfg.jwtKeys)
+	if err != nil {
+		return principal{}, &invalidTokenError{msg: "Access token is invalid or expired", err: err}
+	}
+	return principal{
+		UserID:    access.UserID,
+		SessionID: access.SessionID,
+		ClientID:  access.ClientID,
+		Scopes:    access.Scopes,
+	}, nil
+}
+
+func (cfg *apiConfig) authenticateAPIKey(ctx context.Context, key string) (principal, error) {
+	apiKey, err := cfg.db.GetAPIKeyByHash(ctx, auth.HashToken(key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return principal{}, &invalidTokenError{msg: "API key is invalid or revoked", err: err}
+	}
+	if err != nil {
+		return principal{}, err
+	}
+	now := time.Now().UTC()
+	if now.After(apiKey.ExpiresAt) {
+		return principal{}, &invalidTokenError{msg: "API key has expired"}
+	}
+
+	err = cfg.db.TouchAPIKey(ctx, database.TouchAPIKeyParams{
+		ID:         apiKey.ID,
+		LastUsedAt: sql.NullTime{Time: now, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Error recording API key use: %s", err)
+	}
+
+	return principal{
+		UserID:   apiKey.UserID,
+		APIKeyID: apiKey.ID,
+		Scopes:   apiKey.Scopes,
+	}, nil
+}
+
+// routeAuth says how a route authenticates its caller.
+type routeAuth struct {
+	// Optional routes also serve anonymous requests, which get the zero
+	// principal. A request that does send a token must still send a good
+	// one, so a client with an expired token finds out.
+	Optional bool
+	// Scope is what the caller's token needs, if anything. It's only
+	// checked when there is a caller.
+	Scope string
+}
+
+// withAuth is the one place requests are authenticated. It puts the
+// caller in the request context for next to get from principalFrom.
+func (cfg *apiConfig) withAuth(opts routeAuth, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := cfg.authenticate(r)
+		var invalid *invalidTokenError
+		switch {
+		case errors.Is(err, auth.ErrNoAuthHeaderIncluded) && opts.Optional:
+			next(w, r)
+			return
+		case errors.Is(err, auth.ErrNoAuthHeaderIncluded):
+			respondUnauthorized(w, "", "Access token is required", err)
+			return
+		case errors.As(err, &invalid):
+			respondUnauthorized(w, "invalid_token", invalid.msg, err)
+			return
+		case err != nil:
+			respondWithError(w, http.StatusInternalServerError, "Couldn't validate access token", err)
+			return
+		}
+
+		if opts.Scope != "" && !auth.HasScope(p.Scopes, opts.Scope) {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q, error="insufficient_scope", scope=%q`, authRealm, opts.Scope))
+			respondWithError(w, http.StatusForbidden, "Access token is missing the "+opts.Scope+" scope", nil)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	}
+}
+
+// requireScope is withAuth for the common case of a route that needs a
+// caller with scope.
+func (cfg *apiConfig) requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return cfg.withAuth(routeAuth{Scope: scope}, next)
+}
+
+// optionalAuth is withAuth for public routes that personalise their
+// responses for a caller.
+func (cfg *apiConfig) optionalAuth(next http.HandlerFunc) http.HandlerFunc {
+	return cfg.withAuth(routeAuth{Optional: true}, next)
+}
+
+const authRealm = "chirpy"
+
+// respondUnauthorized sends a 401 with the RFC 6750 WWW-Authenticate
+// challenge. errCode is left out when the request had no token at all.
+func respondUnauthorized(w http.ResponseWriter, errCode, msg string, err error) {
+	challenge := fmt.Sprintf("Bearer realm=%q", authRealm)
+	if errCode != "" {
+		challenge += fmt.Sprintf(", error=%q, error_description=%q", errCode, msg)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	respondWithError(w, http.StatusUnauthorized, msg, err)
+}
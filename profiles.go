@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/database"
+	"github.com/mrcordova/chirpy/internal/entities"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxLocationLength    = 30
+)
+
+var errHandleTaken = errors.New("Handle is already taken")
+
+// profileError is a problem with the profile fields a client sent, as
+// opposed to a failure looking them up.
+type profileError struct {
+	msg string
+}
+
+func (e *profileError) Error() string {
+	return e.msg
+}
+
+// Profile is the public view of a user, without anything private like their
+// email address.
+type Profile struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Handle      *string   `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	Location    string    `json:"location"`
+	AvatarURL   *string   `json:"avatar_url"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+}
+
+// Author is the compact form of a profile embedded in chirps.
+type Author struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      *string   `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   *string   `json:"avatar_url"`
+}
+
+func nullStringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+// avatarURL looks up the URL for a user's avatar, if they have one.
+func (cfg *apiConfig) avatarURL(ctx context.Context, mediaID uuid.NullUUID) (*string, error) {
+	if !mediaID.Valid {
+		return nil, nil
+	}
+	avatar, err := cfg.db.GetMedia(ctx, mediaID.UUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	url := cfg.storage.URL(avatar.ThumbnailKey)
+	return &url, nil
+}
+
+func (cfg *apiConfig) userFromDB(ctx context.Context, dbUser database.User) (User, error) {
+	avatarURL, err := cfg.avatarURL(ctx, dbUser.AvatarMediaID)
+	if err != nil {
+		return User{}, err
+	}
+	isChirpyRed, err := cfg.isChirpyRed(ctx, dbUser.ID)
+	if err != nil {
+		return User{}, err
+	}
+	return User{
+		ID:            dbUser.ID,
+		CreatedAt:     dbUser.CreatedAt,
+		UpdatedAt:     dbUser.UpdatedAt,
+		Email:         dbUser.Email,
+		EmailVerified: dbUser.EmailVerifiedAt.Valid,
+		IsChirpyRed:   isChirpyRed,
+		Handle:        nullStringPtr(dbUser.Handle),
+		DisplayName:   dbUser.DisplayName,
+		Bio:           dbUser.Bio,
+		Location:      dbUser.Location,
+		AvatarURL:     avatarURL,
+
+		TwoFactorEnabled: dbUser.TotpEnabledAt.Valid,
+	}, nil
+}
+
+func (cfg *apiConfig) profileFromDB(ctx context.Context, dbUser database.User) (Profile, error) {
+	avatarURL, err := cfg.avatarURL(ctx, dbUser.AvatarMediaID)
+	if err != nil {
+		return Profile{}, err
+	}
+	isChirpyRed, err := cfg.isChirpyRed(ctx, dbUser.ID)
+	if err != nil {
+		return Profile{}, err
+	}
+	return newProfile(dbUser, avatarURL, isChirpyRed), nil
+}
+
+func newProfile(dbUser database.User, avatarURL *string, isChirpyRed bool) Profile {
+	return Profile{
+		ID:          dbUser.ID,
+		CreatedAt:   dbUser.CreatedAt,
+		Handle:      nullStringPtr(dbUser.Handle),
+		DisplayName: dbUser.DisplayName,
+		Bio:         dbUser.Bio,
+		Location:    dbUser.Location,
+		AvatarURL:   avatarURL,
+		IsChirpyRed: isChirpyRed,
+	}
+}
+
+// profileUpdate holds the profile fields a client sent. Fields left out of
+// the request are nil and keep their current value.
+type profileUpdate struct {
+	Handle        *string `json:"handle"`
+	DisplayName   *string `json:"display_name"`
+	Bio           *string `json:"bio"`
+	Location      *string `json:"location"`
+	AvatarMediaId *string `json:"avatar_media_id"`
+}
+
+// applyProfileUpdate validates the fields in update and merges them into
+// the user's current profile. An empty avatar_media_id removes the avatar.
+func applyProfileUpdate(ctx context.Context, db *database.Queries, user database.User, update profileUpdate) (database.UpdateUserProfileParams, error) {
+	params := database.UpdateUserProfileParams{
+		Handle:        user.Handle,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
+		Location:      user.Location,
+		AvatarMediaID: user.AvatarMediaID,
+		ID:            user.ID,
+	}
+
+	if update.Handle != nil {
+		handle := *update.Handle
+		if err := entities.ValidateHandle(handle); err != nil {
+			return params, &profileError{msg: err.Error()}
+		}
+		existing, err := db.GetUserByHandle(ctx, handle)
+		if err == nil && existing.ID != user.ID {
+			return params, errHandleTaken
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return params, err
+		}
+		params.Handle = sql.NullString{String: handle, Valid: true}
+	}
+
+	fields := []struct {
+		name  string
+		value *string
+		max   int
+		dest  *string
+	}{
+		{"Display name", update.DisplayName, maxDisplayNameLength, &params.DisplayName},
+		{"Bio", update.Bio, maxBioLength, &params.Bio},
+		{"Location", update.Location, maxLocationLength, &params.Location},
+	}
+	for _, field := range fields {
+		if field.value == nil {
+			continue
+		}
+		if utf8.RuneCountInString(*field.value) > field.max {
+			return params, &profileError{msg: fmt.Sprintf("%s must be at most %d characters", field.name, field.max)}
+		}
+		*field.dest = *field.value
+	}
+
+	if update.AvatarMediaId != nil {
+		params.AvatarMediaID = uuid.NullUUID{}
+		if *update.AvatarMediaId != "" {
+			id, err := uuid.Parse(*update.AvatarMediaId)
+			if err != nil {
+				return params, &profileError{msg: "Invalid avatar_media_id"}
+			}
+			avatar, err := db.GetMedia(ctx, id)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return params, err
+			}
+			if err != nil || avatar.UserID != user.ID {
+				return params, &profileError{msg: "Avatar media not found"}
+			}
+			params.AvatarMediaID = uuid.NullUUID{UUID: id, Valid: true}
+		}
+	}
+
+	return params, nil
+}
+
+func (cfg *apiConfig) handlerUserProfile(w http.ResponseWriter, r *http.Request) {
+	handleOrID := r.PathValue("handleOrID")
+
+	var dbUser database.User
+	var err error
+	if id, parseErr := uuid.Parse(handleOrID); parseErr == nil {
+		dbUser, err = cfg.db.GetUserByID(r.Context(), id)
+	} else {
+		dbUser, err = cfg.db.GetUserByHandle(r.Context(), entities.NormalizeHandle(handleOrID))
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve user", err)
+		return
+	}
+	if dbUser.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "User not found", nil)
+		return
+	}
+
+	profile, err := cfg.profileFromDB(r.Context(), dbUser)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve user", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, profile)
+}
+
+// fillAuthors embeds a compact author profile in each chirp.
+func (cfg *apiConfig) fillAuthors(r *http.Request, chirps []*Chirp) error {
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		if !chirp.Deleted && chirp.UserId != nil {
+			ids = append(ids, *chirp.UserId)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := cfg.db.ListUserProfiles(r.Context(), ids)
+	if err != nil {
+		return err
+	}
+	authors := make(map[uuid.UUID]*Author, len(rows))
+	for _, row := range rows {
+		author := &Author{
+			ID:          row.ID,
+			Handle:      nullStringPtr(row.Handle),
+			DisplayName: row.DisplayName,
+		}
+		if row.AvatarKey.Valid {
+			url := cfg.storage.URL(row.AvatarKey.String)
+			author.AvatarURL = &url
+		}
+		authors[row.ID] = author
+	}
+	for _, chirp := range chirps {
+		if chirp.Deleted || chirp.UserId == nil {
+			continue
+		}
+		chirp.Author = authors[*chirp.UserId]
+		// Deleted accounts are left out of the profiles. Their chirps are
+		// hidden like deleted ones until the account is restored.
+		if chirp.Author == nil {
+			hideChirp(chirp)
+		}
+	}
+	return nil
+}
+
+// hideChirp blanks chirp into a deleted placeholder.
+func hideChirp(chirp *Chirp) {
+	chirp.Body = ""
+	chirp.UserId = nil
+	chirp.Entities = chirpEntities("")
+	chirp.Edited = false
+	chirp.Deleted = true
+	chirp.RechirpOf = nil
+	chirp.QuotedChirp = nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/database"
+)
+
+// decorateChirps fills in everything about a chirp that needs more than its
+// own row: its author, the chirps it reposts or quotes, its attachments, and
+// the caller's likes.
+func (cfg *apiConfig) decorateChirps(r *http.Request, chirps []*Chirp) error {
+	ids := []uuid.UUID{}
+	for _, chirp := range chirps {
+		if chirp.rechirpOfID.Valid {
+			ids = append(ids, chirp.rechirpOfID.UUID)
+		}
+		if chirp.quotedChirpID.Valid {
+			ids = append(ids, chirp.quotedChirpID.UUID)
+		}
+	}
+
+	embedded := []*Chirp{}
+	if len(ids) > 0 {
+		dbChirps, err := cfg.db.GetChirpsByIDs(r.Context(), ids)
+		if err != nil {
+			return err
+		}
+		found := make(map[uuid.UUID]database.Chirp, len(dbChirps))
+		for _, dbChirp := range dbChirps {
+			found[dbChirp.ID] = dbChirp
+		}
+
+		// A quoted chirp that has since been deleted is still referenced,
+		// so it is shown as a deleted placeholder.
+		embed := func(id uuid.NullUUID) *Chirp {
+			if !id.Valid {
+				return nil
+			}
+			dbChirp, ok := found[id.UUID]
+			if !ok {
+				return &Chirp{Id: id.UUID, Deleted: true}
+			}
+			chirp := chirpFromDB(dbChirp)
+			embedded = append(embedded, &chirp)
+			return &chirp
+		}
+		for _, chirp := range chirps {
+			chirp.RechirpOf = embed(chirp.rechirpOfID)
+			chirp.QuotedChirp = embed(chirp.quotedChirpID)
+		}
+	}
+
+	all := append(chirps, embedded...)
+	if err := cfg.fillAuthors(r, all); err != nil {
+		return err
+	}
+	if err := cfg.fillChirpMedia(r, all); err != nil {
+		return err
+	}
+	return cfg.fillLikedByMe(r, all)
+}
+
+func (cfg *apiConfig) handlerRechirp(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r.Context()).UserID
+	if !cfg.requireVerified(w, r, userID, actionChirp) {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	original, err := cfg.db.GetChirp(r.Context(), chirpID)
+	if err != nil || original.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	// Rechirping a rechirp reposts the original chirp.
+	originalID := uuid.NullUUID{UUID: original.ID, Valid: true}
+	if original.RechirpOfID.Valid {
+		originalID = original.RechirpOfID
+	}
+
+	dbChirp, err := cfg.db.CreateRechirp(r.Context(), database.CreateRechirpParams{
+		UserID:      uuid.NullUUID{UUID: userID, Valid: true},
+		RechirpOfID: originalID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "Chirp already rechirped", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rechirp", err)
+		return
+	}
+
+	chirp := chirpFromDB(dbChirp)
+	if err := cfg.decorateChirps(r, []*Chirp{&chirp}); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, chirp)
+}
+
+func (cfg *apiConfig) handlerUnrechirp(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r.Context()).UserID
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	removed, err := cfg.db.DeleteRechirp(r.Context(), database.DeleteRechirpParams{
+		UserID:      uuid.NullUUID{UUID: userID, Valid: true},
+		RechirpOfID: uuid.NullUUID{UUID: chirpID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't undo rechirp", err)
+		return
+	}
+	if removed == 0 {
+		respondWithError(w, http.StatusNotFound, "Chirp not rechirped", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/database"
+)
+
+const maxSearchUsers = 10
+
+func (cfg *apiConfig) handlerSearch(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Chirps     []Chirp `json:"chirps"`
+		Users      []Profile `json:"users"`
+		NextCursor string  `json:"next_cursor,omitempty"`
+		PrevCursor string  `json:"prev_cursor,omitempty"`
+	}
+	query := r.URL.Query()
+
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		respondWithError(w, http.StatusBadRequest, "q is required", nil)
+		return
+	}
+
+	authorID := uuid.NullUUID{}
+	if s := query.Get("author_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author_id", err)
+			return
+		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	since, err := parseTimeParam(query.Get("since"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "since must be an RFC 3339 timestamp", err)
+		return
+	}
+	until, err := parseTimeParam(query.Get("until"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "until must be an RFC 3339 timestamp", err)
+		return
+	}
+
+	limit, err := parsePageLimit(query.Get("limit"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	offset, err := decodeOffsetCursor(query.Get("cursor"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	rows, err := cfg.db.SearchChirps(r.Context(), database.SearchChirpsParams{
+		Query:      q,
+		AuthorID:   authorID,
+		Since:      since,
+		Until:      until,
+		PageLimit:  int32(limit + 1),
+		PageOffset: int32(offset),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't search chirps", err)
+		return
+	}
+
+	resp := response{Chirps: []Chirp{}, Users: []Profile{}}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		resp.NextCursor = encodeOffsetCursor(offset + limit)
+	}
+	if offset > 0 {
+		resp.PrevCursor = encodeOffsetCursor(max(offset-limit, 0))
+	}
+	for _, row := range rows {
+		resp.Chirps = append(resp.Chirps, chirpFromDB(row.Chirp))
+	}
+	if err := cfg.decorateChirps(r, chirpRefs(resp.Chirps)); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't search chirps", err)
+		return
+	}
+
+	// Matching users only come back with the first page of chirps.
+	if offset == 0 {
+		rows, err := cfg.db.SearchUsers(r.Context(), database.SearchUsersParams{
+			Prefix:    escapeLike(q),
+			PageLimit: maxSearchUsers,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't search users", err)
+			return
+		}
+		resp.Users, err = cfg.searchProfiles(r, rows)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't search users", err)
+			return
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// searchProfiles builds profiles for the users a search matched, loading
+// their subscriptions in one query.
+func (cfg *apiConfig) searchProfiles(r *http.Request, rows []database.SearchUsersRow) ([]Profile, error) {
+	if len(rows) == 0 {
+		return []Profile{}, nil
+	}
+	ids := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.User.ID)
+	}
+	subs, err := cfg.db.ListSubscriptions(r.Context(), ids)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	members := make(map[uuid.UUID]bool, len(subs))
+	for _, sub := range subs {
+		members[sub.UserID] = subscriptionIsActive(sub, now)
+	}
+
+	profiles := make([]Profile, 0, len(rows))
+	for _, row := range rows {
+		var avatarURL *string
+		if row.AvatarKey.Valid {
+			url := cfg.storage.URL(row.AvatarKey.String)
+			avatarURL = &url
+		}
+		profiles = append(profiles, newProfile(row.User, avatarURL, members[row.User.ID]))
+	}
+	return profiles, nil
+}
+
+func parseTimeParam(s string) (sql.NullTime, error) {
+	if s == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}, nil
+}
+
+// escapeLike stops user input from being read as LIKE wildcards.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
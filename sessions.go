@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/auth"
+	"github.com/mrcordova/chirpy/internal/database"
+)
+
+type Session struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	UserAgent string    `json:"user_agent"`
+	IpAddress string    `json:"ip_address"`
+	// ClientID is the OAuth client that started the session, if any.
+	ClientID *string `json:"client_id"`
+}
+
+// clientIP returns the address of the peer that made the request.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (cfg *apiConfig) handlerSessionsList(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r.Context()).UserID
+
+	dbSessions, err := cfg.db.ListActiveSessions(r.Context(), database.ListActiveSessionsParams{
+		UserID: userID,
+		Now:    time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve sessions", err)
+		return
+	}
+
+	sessions := make([]Session, 0, len(dbSessions))
+	for _, dbSession := range dbSessions {
+		sessions = append(sessions, Session{
+			ID:        dbSession.SessionID,
+			CreatedAt: dbSession.CreatedAt,
+			ExpiresAt: dbSession.ExpiresAt,
+			UserAgent: dbSession.UserAgent,
+			IpAddress: dbSession.IpAddress,
+			ClientID:  nullStringPtr(dbSession.ClientID),
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, sessions)
+}
+
+func (cfg *apiConfig) handlerSessionDelete(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r.Context()).UserID
+
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid session ID", err)
+		return
+	}
+
+	revoked, err := cfg.db.RevokeSession(r.Context(), database.RevokeSessionParams{
+		SessionID: sessionID,
+		UserID:    userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "Session not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerSessionsDeleteAll(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r.Context()).UserID
+
+	if err := cfg.db.RevokeUserSessions(r.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startSession begins a login session: a new refresh token family and the
+// first access token in it. Sessions started by an OAuth client carry its
+// ID and only the scopes the user granted it; a nil scopes gives a
+// password login's full set.
+func (cfg *apiConfig) startSession(r *http.Request, userID uuid.UUID, clientID sql.NullString, scopes []string) (string, string, error) {
+	sessionID := uuid.New()
+	accessToken, err := auth.MakeAccessToken(sessionAccessToken(userID, sessionID, clientID, scopes), cfg.jwtKeys, accessTokenDuration)
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = cfg.db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+		Token:     refreshToken,
+		ExpiresAt: time.Now().UTC().Add(refreshTokenDuration),
+		UserID:    userID,
+		SessionID: sessionID,
+		UserAgent: r.UserAgent(),
+		IpAddress: clientIP(r),
+		ClientID:  clientID,
+		Scopes:    scopes,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+// refreshTokenReused revokes every token issued after token, which was
+// presented again after being exchanged. It has been copied somewhere, so
+// the whole family is suspect.
+func (cfg *apiConfig) refreshTokenReused(ctx context.Context, token string) error {
+	err := cfg.db.RevokeRefreshTokenDescendants(ctx, sql.NullString{String: token, Valid: true})
+	if err != nil {
+		return err
+	}
+	return &refreshTokenError{msg: "Refresh token has already been used"}
+}
+
+func sessionAccessToken(userID, sessionID uuid.UUID, clientID sql.NullString, scopes []string) auth.AccessToken {
+	if scopes == nil {
+		scopes = auth.SessionScopes
+	}
+	return auth.AccessToken{
+		UserID:    userID,
+		SessionID: sessionID,
+		ClientID:  clientID.String,
+		Scopes:    scopes,
+	}
+}
+
+// refreshTokenError is a refresh token the client shouldn't have sent, as
+// opposed to a failure rotating it.
+type refreshTokenError struct {
+	msg string
+	err error
+}
+
+func (e *refreshTokenError) Error() string {
+	return e.msg
+}
+
+// rotateRefreshToken exchanges a refresh token for a new one and a fresh
+// access token in the same session. clientID must match the client the
+// session was started by, so an OAuth client's tokens can only be
+// refreshed by that client.
+func (cfg *apiConfig) rotateRefreshToken(r *http.Request, token string, clientID sql.NullString) (string, string, error) {
+	dbToken, err := cfg.db.GetRefreshToken(r.Context(), token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", &refreshTokenError{msg: "Couldn't get user for refresh token", err: err}
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	if dbToken.RotatedAt.Valid {
+		return "", "", cfg.refreshTokenReused(r.Context(), dbToken.Token)
+	}
+	if dbToken.RevokedAt.Valid {
+		return "", "", &refreshTokenError{msg: "Refresh token has been revoked"}
+	}
+	if time.Now().UTC().After(dbToken.ExpiresAt) {
+		return "", "", &refreshTokenError{msg: "Refresh token has expired"}
+	}
+	if dbToken.ClientID != clientID {
+		return "", "", &refreshTokenError{msg: "Refresh token was issued to another client"}
+	}
+
+	accessToken, err := auth.MakeAccessToken(
+		sessionAccessToken(dbToken.UserID, dbToken.SessionID, dbToken.ClientID, dbToken.Scopes),
+		cfg.jwtKeys,
+		accessTokenDuration,
+	)
+	if err != nil {
+		return "", "", err
+	}
+
+	newRefreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	_, err = qtx.RotateRefreshToken(r.Context(), dbToken.Token)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Another request exchanged the same token first, which is reuse
+		// all the same.
+		tx.Rollback()
+		return "", "", cfg.refreshTokenReused(r.Context(), dbToken.Token)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = qtx.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+		Token:       newRefreshToken,
+		ExpiresAt:   time.Now().UTC().Add(refreshTokenDuration),
+		UserID:      dbToken.UserID,
+		ParentToken: sql.NullString{String: dbToken.Token, Valid: true},
+		SessionID:   dbToken.SessionID,
+		UserAgent:   dbToken.UserAgent,
+		IpAddress:   dbToken.IpAddress,
+		ClientID:    dbToken.ClientID,
+		Scopes:      dbToken.Scopes,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", "", err
+	}
+	return accessToken, newRefreshToken, nil
+}
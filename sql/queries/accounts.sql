@@ -0,0 +1,111 @@
+-- name: SoftDeleteUser :one
+UPDATE users
+SET deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: RestoreUser :one
+UPDATE users
+SET deleted_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: LockUserChirps :exec
+SELECT id FROM chirps
+WHERE user_id = $1
+FOR UPDATE;
+
+-- name: TombstoneUserChirps :many
+UPDATE chirps
+SET body = '', user_id = NULL, deleted_at = COALESCE(deleted_at, NOW()), updated_at = NOW()
+WHERE user_id = $1 AND reply_count > 0
+RETURNING id;
+
+-- name: DecrementUserReplyParents :exec
+UPDATE chirps
+SET reply_count = GREATEST(chirps.reply_count - replies.reply_count, 0)
+FROM (
+    SELECT in_reply_to_id, COUNT(*)::int AS reply_count FROM chirps
+    WHERE user_id = $1 AND in_reply_to_id IS NOT NULL
+    GROUP BY in_reply_to_id
+) AS replies
+WHERE chirps.id = replies.in_reply_to_id;
+
+-- name: DecrementUserLikedChirps :exec
+UPDATE chirps
+SET like_count = GREATEST(like_count - 1, 0)
+WHERE id IN (
+    SELECT chirp_id FROM likes
+    WHERE user_id = $1
+);
+
+-- name: LockUserForPurge :one
+-- Returns no rows if the account was restored, or isn't due to be purged.
+SELECT id FROM users
+WHERE id = sqlc.arg('id')
+AND deleted_at IS NOT NULL
+AND deleted_at <= NOW() - sqlc.arg('grace_seconds')::float8 * INTERVAL '1 second'
+FOR UPDATE;
+
+-- name: DeleteUser :exec
+DELETE FROM users
+WHERE id = $1 AND deleted_at IS NOT NULL;
+
+-- name: ListUsersPendingDeletion :many
+SELECT id FROM users
+WHERE deleted_at IS NOT NULL
+AND deleted_at <= NOW() - sqlc.arg('grace_seconds')::float8 * INTERVAL '1 second';
+
+-- name: ListUserMediaKeys :many
+SELECT storage_key, thumbnail_key FROM media
+WHERE user_id = $1;
+
+-- name: ListUserChirps :many
+SELECT * FROM chirps
+WHERE user_id = $1
+ORDER BY created_at ASC, id ASC;
+
+-- name: CreateDataExport :one
+INSERT INTO data_exports(id, created_at, updated_at, user_id, status, storage_key, expires_at)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, 'pending', NULL, NULL
+)
+ON CONFLICT (user_id) WHERE status = 'pending' DO NOTHING
+RETURNING *;
+
+-- name: GetLatestDataExport :one
+SELECT * FROM data_exports
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: GetDataExport :one
+SELECT * FROM data_exports
+WHERE id = $1 AND user_id = $2 LIMIT 1;
+
+-- name: CompleteDataExport :exec
+UPDATE data_exports
+SET status = 'ready', storage_key = $2, expires_at = $3, updated_at = NOW()
+WHERE id = $1;
+
+-- name: FailDataExport :exec
+UPDATE data_exports
+SET status = 'failed', updated_at = NOW()
+WHERE id = $1;
+
+-- name: FailPendingDataExports :exec
+UPDATE data_exports
+SET status = 'failed', updated_at = NOW()
+WHERE status = 'pending';
+
+-- name: ListExpiredDataExports :many
+SELECT * FROM data_exports
+WHERE status = 'ready' AND expires_at <= sqlc.arg('now');
+
+-- name: DeleteDataExport :exec
+DELETE FROM data_exports
+WHERE id = $1;
+
+-- name: ListUserDataExportKeys :many
+SELECT storage_key FROM data_exports
+WHERE user_id = $1 AND storage_key IS NOT NULL;
@@ -0,0 +1,32 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys(id, created_at, user_id, label, key_hash, prefix, scopes, expires_at, last_used_at, revoked_at)
+VALUES (
+    gen_random_uuid(), NOW(), $1, $2, $3, $4, $5, $6, NULL, NULL
+)
+RETURNING *;
+
+-- name: GetAPIKeyByHash :one
+SELECT * FROM api_keys
+WHERE key_hash = $1 AND revoked_at IS NULL;
+
+-- name: ListAPIKeys :many
+SELECT * FROM api_keys
+WHERE user_id = sqlc.arg('user_id')
+AND revoked_at IS NULL
+AND expires_at > sqlc.arg('now')
+ORDER BY created_at DESC;
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = $2
+WHERE id = $1;
+
+-- name: RevokeAPIKey :execrows
+UPDATE api_keys
+SET revoked_at = NOW()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeUserAPIKeys :exec
+UPDATE api_keys
+SET revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
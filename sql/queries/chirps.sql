@@ -0,0 +1,120 @@
+-- name: ListChirpsAfter :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id'))
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.deleted_at IS NOT NULL
+)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListChirpsBefore :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id'))
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.deleted_at IS NOT NULL
+)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: GetChirpForUpdate :one
+SELECT * FROM chirps
+WHERE id = $1
+FOR UPDATE;
+
+-- name: IncrementReplyCount :exec
+UPDATE chirps
+SET reply_count = reply_count + 1
+WHERE id = $1;
+
+-- name: DecrementReplyCount :exec
+UPDATE chirps
+SET reply_count = GREATEST(reply_count - 1, 0)
+WHERE id = $1;
+
+-- name: TombstoneChirp :one
+UPDATE chirps
+SET body = '', deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: ListChirpAncestors :many
+SELECT * FROM chirps
+WHERE id IN (
+    WITH RECURSIVE ancestors AS (
+        SELECT c.in_reply_to_id AS id FROM chirps c
+        WHERE c.id = sqlc.arg('chirp_id')::uuid
+        UNION
+        SELECT c.in_reply_to_id FROM chirps c
+        JOIN ancestors a ON c.id = a.id
+    )
+    SELECT ancestors.id FROM ancestors
+    WHERE ancestors.id IS NOT NULL
+)
+ORDER BY created_at ASC, id ASC;
+
+-- name: ListChirpReplies :many
+SELECT * FROM chirps
+WHERE id IN (
+    WITH RECURSIVE replies AS (
+        SELECT c.id FROM chirps c
+        WHERE c.in_reply_to_id = sqlc.arg('chirp_id')::uuid
+        UNION
+        SELECT c.id FROM chirps c
+        JOIN replies r ON c.in_reply_to_id = r.id
+    )
+    SELECT replies.id FROM replies
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg('ids')::uuid[]);
+
+-- name: CreateRechirp :one
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, rechirp_of_id)
+VALUES(
+    gen_random_uuid(), NOW(), NOW(), '', $1, $2
+)
+ON CONFLICT (user_id, rechirp_of_id) WHERE rechirp_of_id IS NOT NULL DO NOTHING
+RETURNING *;
+
+-- name: DeleteRechirp :execrows
+DELETE FROM chirps
+WHERE user_id = $1 AND rechirp_of_id = $2;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = sqlc.arg('body'), updated_at = NOW(), edited_at = NOW()
+WHERE id = sqlc.arg('id')
+AND user_id = sqlc.arg('user_id')
+AND deleted_at IS NULL
+AND created_at > NOW() - sqlc.arg('window_seconds')::float8 * INTERVAL '1 second'
+RETURNING *;
+
+-- name: CreateChirpRevision :exec
+INSERT INTO chirp_revisions(id, chirp_id, body, created_at, replaced_at)
+VALUES (
+    gen_random_uuid(), $1, $2, $3, NOW()
+);
+
+-- name: ListChirpRevisions :many
+SELECT * FROM chirp_revisions
+WHERE chirp_id = $1
+ORDER BY replaced_at ASC;
+
+-- name: DeleteChirpRevisions :exec
+DELETE FROM chirp_revisions
+WHERE chirp_id = $1;
@@ -0,0 +1,62 @@
+-- name: CreateChirpHashtag :exec
+INSERT INTO chirp_hashtags(chirp_id, tag, start_offset, end_offset, created_at)
+VALUES (
+    $1, $2, $3, $4, NOW()
+);
+
+-- name: CreateChirpMention :exec
+INSERT INTO chirp_mentions(chirp_id, handle, user_id, start_offset, end_offset)
+VALUES (
+    $1, $2, $3, $4, $5
+);
+
+-- name: DeleteChirpEntities :exec
+WITH deleted_hashtags AS (
+    DELETE FROM chirp_hashtags
+    WHERE chirp_hashtags.chirp_id = $1
+)
+DELETE FROM chirp_mentions
+WHERE chirp_mentions.chirp_id = $1;
+
+-- name: ListHashtagChirpsBefore :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.deleted_at IS NOT NULL
+)
+AND EXISTS (
+    SELECT 1 FROM chirp_hashtags
+    WHERE chirp_hashtags.chirp_id = chirps.id AND chirp_hashtags.tag = sqlc.arg('tag')
+)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListHashtagChirpsAfter :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.deleted_at IS NOT NULL
+)
+AND EXISTS (
+    SELECT 1 FROM chirp_hashtags
+    WHERE chirp_hashtags.chirp_id = chirps.id AND chirp_hashtags.tag = sqlc.arg('tag')
+)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListTrendingHashtags :many
+SELECT tag, COUNT(DISTINCT chirp_id) AS chirp_count FROM chirp_hashtags
+WHERE created_at >= NOW() - sqlc.arg('window_seconds')::float8 * INTERVAL '1 second'
+GROUP BY tag
+ORDER BY chirp_count DESC, tag ASC
+LIMIT sqlc.arg('page_limit');
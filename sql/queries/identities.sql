@@ -0,0 +1,27 @@
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE issuer = $1 AND subject = $2;
+
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities(issuer, subject, created_at, user_id, email)
+VALUES (
+    $1, $2, NOW(), $3, $4
+)
+RETURNING *;
+
+-- name: GetUserByEmailFold :one
+-- Emails only differing in case may belong to different users, so a
+-- verified one is preferred, then the oldest.
+SELECT * FROM users
+WHERE lower(email) = lower(sqlc.arg('email'))
+ORDER BY email_verified_at IS NULL, created_at ASC
+LIMIT 1;
+
+-- name: CreateExternalUser :one
+-- Users signed up through a provider have no password, so the default
+-- hashed_password never matches one.
+INSERT INTO users (id, created_at, updated_at, email, display_name, email_verified_at)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, $2, NOW()
+)
+RETURNING *;
@@ -0,0 +1,57 @@
+-- name: LikeChirp :execrows
+INSERT INTO likes(user_id, chirp_id, created_at)
+VALUES (
+    $1, $2, NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnlikeChirp :execrows
+DELETE FROM likes
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: IncrementLikeCount :exec
+UPDATE chirps
+SET like_count = like_count + 1
+WHERE id = $1;
+
+-- name: DecrementLikeCount :exec
+UPDATE chirps
+SET like_count = GREATEST(like_count - 1, 0)
+WHERE id = $1;
+
+-- name: ListLikedChirpIDs :many
+SELECT chirp_id FROM likes
+WHERE user_id = sqlc.arg('user_id')
+AND chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
+
+-- name: ListUserLikesBefore :many
+SELECT sqlc.embed(chirps), likes.created_at AS liked_at FROM likes
+JOIN chirps ON chirps.id = likes.chirp_id
+WHERE likes.user_id = sqlc.arg('user_id')
+AND chirps.deleted_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.deleted_at IS NOT NULL
+)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (likes.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY likes.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListUserLikesAfter :many
+SELECT sqlc.embed(chirps), likes.created_at AS liked_at FROM likes
+JOIN chirps ON chirps.id = likes.chirp_id
+WHERE likes.user_id = sqlc.arg('user_id')
+AND chirps.deleted_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.deleted_at IS NOT NULL
+)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (likes.created_at, chirps.id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY likes.created_at ASC, chirps.id ASC
+LIMIT sqlc.arg('page_limit');
@@ -0,0 +1,26 @@
+-- name: GetLoginLockouts :many
+SELECT * FROM login_failures
+WHERE key = ANY(sqlc.arg('keys')::text[])
+AND locked_until > sqlc.arg('now');
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_failures(key, failures, last_failed_at, locked_until)
+VALUES (
+    sqlc.arg('key'), 1, sqlc.arg('now'), NULL
+)
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_failures.last_failed_at < sqlc.arg('reset_before') THEN 1
+        ELSE login_failures.failures + 1
+    END,
+    last_failed_at = sqlc.arg('now')
+RETURNING *;
+
+-- name: LockLogin :exec
+UPDATE login_failures
+SET locked_until = $2
+WHERE key = $1;
+
+-- name: ClearLoginFailures :execrows
+DELETE FROM login_failures
+WHERE key = $1;
@@ -0,0 +1,31 @@
+-- name: CreateMedia :one
+INSERT INTO media(id, created_at, user_id, storage_key, thumbnail_key, content_type, size_bytes, width, height)
+VALUES (
+    $1, NOW(), $2, $3, $4, $5, $6, $7, $8
+)
+RETURNING *;
+
+-- name: GetUnattachedMedia :many
+SELECT * FROM media
+WHERE user_id = sqlc.arg('user_id')
+AND id = ANY(sqlc.arg('ids')::uuid[])
+AND NOT EXISTS (
+    SELECT 1 FROM chirp_media
+    WHERE chirp_media.media_id = media.id
+);
+
+-- name: AttachChirpMedia :exec
+INSERT INTO chirp_media(chirp_id, media_id, position)
+VALUES (
+    $1, $2, $3
+);
+
+-- name: ListChirpMedia :many
+SELECT chirp_media.chirp_id, chirp_media.position, sqlc.embed(media) FROM chirp_media
+JOIN media ON media.id = chirp_media.media_id
+WHERE chirp_media.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_media.chirp_id, chirp_media.position;
+
+-- name: GetMedia :one
+SELECT * FROM media
+WHERE id = $1 LIMIT 1;
@@ -0,0 +1,37 @@
+-- name: ListModerationWords :many
+SELECT * FROM moderation_words
+ORDER BY word ASC;
+
+-- name: UpsertModerationWord :one
+INSERT INTO moderation_words(word, action, created_at, updated_at)
+VALUES (
+    $1, $2, NOW(), NOW()
+)
+ON CONFLICT (word) DO UPDATE
+SET action = EXCLUDED.action, updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteModerationWord :execrows
+DELETE FROM moderation_words
+WHERE word = $1;
+
+-- name: UpsertChirpModeration :exec
+INSERT INTO chirp_moderation(chirp_id, action, verdicts, created_at)
+VALUES (
+    $1, $2, $3, NOW()
+)
+ON CONFLICT (chirp_id) DO UPDATE
+SET action = EXCLUDED.action, verdicts = EXCLUDED.verdicts, created_at = NOW(), reviewed_at = NULL;
+
+-- name: ListFlaggedChirps :many
+SELECT sqlc.embed(chirps), chirp_moderation.verdicts FROM chirp_moderation
+JOIN chirps ON chirps.id = chirp_moderation.chirp_id
+WHERE chirp_moderation.action = 'flag'
+AND chirp_moderation.reviewed_at IS NULL
+ORDER BY chirp_moderation.created_at ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ReviewChirpModeration :execrows
+UPDATE chirp_moderation
+SET reviewed_at = NOW()
+WHERE chirp_id = $1 AND reviewed_at IS NULL;
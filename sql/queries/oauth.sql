@@ -0,0 +1,35 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients(id, created_at, user_id, name, secret_hash, redirect_uris, scopes)
+VALUES (
+    $1, NOW(), $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE id = $1;
+
+-- name: ListOAuthClients :many
+SELECT * FROM oauth_clients
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: DeleteOAuthClient :execrows
+DELETE FROM oauth_clients
+WHERE id = $1 AND user_id = $2;
+
+-- name: CreateOAuthCode :exec
+INSERT INTO oauth_codes(code_hash, created_at, expires_at, used_at, client_id, user_id, redirect_uri, scopes, code_challenge)
+VALUES (
+    $1, NOW(), $2, NULL, $3, $4, $5, $6, $7
+);
+
+-- name: UseOAuthCode :one
+UPDATE oauth_codes
+SET used_at = NOW()
+WHERE code_hash = $1 AND used_at IS NULL
+RETURNING *;
+
+-- name: DeleteExpiredOAuthCodes :exec
+DELETE FROM oauth_codes
+WHERE expires_at < $1;
@@ -0,0 +1,28 @@
+-- name: SearchChirps :many
+SELECT sqlc.embed(chirps), ts_rank(chirps.search_vector, websearch_to_tsquery('english', sqlc.arg('query')))::real AS rank
+FROM chirps
+WHERE chirps.search_vector @@ websearch_to_tsquery('english', sqlc.arg('query'))
+AND chirps.deleted_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.deleted_at IS NOT NULL
+)
+AND (sqlc.narg('author_id')::uuid IS NULL OR chirps.user_id = sqlc.narg('author_id'))
+AND (sqlc.narg('since')::timestamp IS NULL OR chirps.created_at >= sqlc.narg('since'))
+AND (sqlc.narg('until')::timestamp IS NULL OR chirps.created_at < sqlc.narg('until'))
+ORDER BY rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit')
+OFFSET sqlc.arg('page_offset');
+
+-- name: SearchUsers :many
+-- Email isn't matched: a prefix search would let anyone dig up other
+-- users' addresses letter by letter.
+SELECT sqlc.embed(users), media.thumbnail_key AS avatar_key FROM users
+LEFT JOIN media ON media.id = users.avatar_media_id
+WHERE users.deleted_at IS NULL
+AND (
+    lower(users.handle) LIKE lower(sqlc.arg('prefix')::text) || '%'
+    OR lower(users.display_name) LIKE lower(sqlc.arg('prefix')::text) || '%'
+)
+ORDER BY users.handle ASC NULLS LAST, users.display_name ASC, users.id ASC
+LIMIT sqlc.arg('page_limit');
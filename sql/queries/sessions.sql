@@ -0,0 +1,22 @@
+-- name: ListActiveSessions :many
+SELECT * FROM refresh_tokens
+WHERE user_id = sqlc.arg('user_id')
+AND revoked_at IS NULL
+AND rotated_at IS NULL
+AND expires_at > sqlc.arg('now')
+ORDER BY created_at DESC;
+
+-- name: RevokeSession :execrows
+UPDATE refresh_tokens
+SET updated_at = NOW(), revoked_at = NOW()
+WHERE session_id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeUserSessions :exec
+UPDATE refresh_tokens
+SET updated_at = NOW(), revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeOtherSessions :exec
+UPDATE refresh_tokens
+SET updated_at = NOW(), revoked_at = NOW()
+WHERE user_id = $1 AND session_id <> $2 AND revoked_at IS NULL;
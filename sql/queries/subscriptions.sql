@@ -0,0 +1,50 @@
+-- name: GetSubscription :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: ListSubscriptions :many
+SELECT * FROM subscriptions
+WHERE user_id = ANY(sqlc.arg('user_ids')::uuid[]);
+
+-- name: GetSubscriptionForUpdate :one
+SELECT * FROM subscriptions
+WHERE user_id = $1
+FOR UPDATE;
+
+-- name: ActivateSubscription :one
+-- Starts or renews a subscription, clearing any cancellation or missed
+-- payment. Returns no rows if a newer event was applied in the meantime.
+INSERT INTO subscriptions (user_id, created_at, updated_at, plan, status, current_period_end, canceled_at, grace_period_end, last_event_id, last_event_at)
+VALUES (
+    $1, NOW(), NOW(), $2, 'active', $3, NULL, NULL, $4, $5
+)
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan,
+    status = 'active',
+    current_period_end = EXCLUDED.current_period_end,
+    canceled_at = NULL,
+    grace_period_end = NULL,
+    last_event_id = EXCLUDED.last_event_id,
+    last_event_at = EXCLUDED.last_event_at,
+    updated_at = NOW()
+WHERE subscriptions.last_event_at IS NULL
+OR subscriptions.last_event_at <= EXCLUDED.last_event_at
+RETURNING *;
+
+-- name: CancelSubscription :one
+UPDATE subscriptions
+SET status = 'canceled', canceled_at = NOW(), last_event_id = $2, last_event_at = $3, updated_at = NOW()
+WHERE user_id = $1 AND status IN ('active', 'past_due')
+RETURNING *;
+
+-- name: MarkSubscriptionPastDue :one
+UPDATE subscriptions
+SET status = 'past_due', grace_period_end = $2, last_event_id = $3, last_event_at = $4, updated_at = NOW()
+WHERE user_id = $1 AND status IN ('active', 'past_due')
+RETURNING *;
+
+-- name: ExpireSubscriptions :execrows
+UPDATE subscriptions
+SET status = 'expired', updated_at = NOW()
+WHERE (status IN ('active', 'canceled') AND current_period_end <= sqlc.arg('now'))
+OR (status = 'past_due' AND grace_period_end <= sqlc.arg('now'));
@@ -0,0 +1,26 @@
+-- name: CreateUserToken :one
+INSERT INTO user_tokens(token_hash, created_at, expires_at, used_at, user_id, purpose)
+VALUES (
+    $1, NOW(), $2, NULL, $3, $4
+)
+RETURNING *;
+
+-- name: UseUserToken :one
+UPDATE user_tokens
+SET used_at = NOW()
+WHERE token_hash = sqlc.arg('token_hash')
+AND purpose = sqlc.arg('purpose')
+AND used_at IS NULL
+AND expires_at > sqlc.arg('now')
+RETURNING *;
+
+-- name: InvalidateUserTokens :exec
+UPDATE user_tokens
+SET used_at = NOW()
+WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL;
+
+-- name: MarkEmailVerified :one
+UPDATE users
+SET email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW()
+WHERE id = $1
+RETURNING *;
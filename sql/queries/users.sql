@@ -10,9 +10,9 @@ DELETE FROM users;
 
 
 -- name: CreateChirp :one
-INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, in_reply_to_id, quoted_chirp_id)
 VALUES(
-    gen_random_uuid(), NOW(), NOW(), $1, $2
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4
 )
 RETURNING *;
 
@@ -31,9 +31,9 @@ WHERE id = $1 LIMIT 1;
 
 
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens(token, created_at, updated_at, expires_at, revoked_at, user_id)
+INSERT INTO refresh_tokens(token, created_at, updated_at, expires_at, revoked_at, user_id, parent_token, session_id, user_agent, ip_address, client_id, scopes)
 VALUES (
-    $1, NOw(), NOw(), $2, $3, $4
+    $1, NOw(), NOw(), $2, $3, $4, $5, $6, $7, $8, $9, $10
 )
 RETURNING *;
 
@@ -46,9 +46,28 @@ UPDATE refresh_tokens
 SET updated_at = NOW(), revoked_at = NOw()
 WHERE token = $1;
 
+-- name: RotateRefreshToken :one
+UPDATE refresh_tokens
+SET updated_at = NOW(), rotated_at = NOW()
+WHERE token = $1 AND rotated_at IS NULL AND revoked_at IS NULL
+RETURNING *;
+
+-- name: RevokeRefreshTokenDescendants :exec
+WITH RECURSIVE descendants AS (
+    SELECT token FROM refresh_tokens
+    WHERE parent_token = $1
+    UNION
+    SELECT rt.token FROM refresh_tokens rt
+    JOIN descendants d ON rt.parent_token = d.token
+)
+UPDATE refresh_tokens
+SET updated_at = NOW(), revoked_at = NOW()
+WHERE token IN (SELECT token FROM descendants) AND revoked_at IS NULL;
+
 -- name: UpdateUser :one
 UPDATE users
-SET email = $1, hashed_password = $2, updated_at = NOW()
+SET email = $1, hashed_password = $2, updated_at = NOW(),
+    email_verified_at = CASE WHEN email = $1 THEN email_verified_at ELSE NULL END
 WHERE id = $3
 RETURNING *;
 
@@ -57,8 +76,22 @@ DELETE FROM chirps
 WHERE id = $1 AND user_id = $2
 RETURNING *;
 
--- name: UpdateUserMembership :one
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1 LIMIT 1;
+
+-- name: GetUserByHandle :one
+SELECT * FROM users
+WHERE lower(handle) = lower($1) LIMIT 1;
+
+-- name: UpdateUserProfile :one
 UPDATE users
-SET is_chirpy_red = $1, updated_at = NOW()
-WHERE id = $2
-RETURNING *;
\ No newline at end of file
+SET handle = $1, display_name = $2, bio = $3, location = $4, avatar_media_id = $5, updated_at = NOW()
+WHERE id = $6
+RETURNING *;
+
+-- name: ListUserProfiles :many
+SELECT users.id, users.handle, users.display_name, media.thumbnail_key AS avatar_key FROM users
+LEFT JOIN media ON media.id = users.avatar_media_id
+WHERE users.id = ANY(sqlc.arg('ids')::uuid[])
+AND users.deleted_at IS NULL;
@@ -0,0 +1,55 @@
+-- name: CreateWebhookEvent :one
+-- Returns no rows if the event was already recorded.
+INSERT INTO webhook_events(id, received_at, source, event_id, event_type, payload, status, error, attempts, processed_at)
+VALUES (
+    gen_random_uuid(), NOW(), $1, $2, $3, $4, 'received', NULL, 0, NULL
+)
+ON CONFLICT (source, event_id) DO NOTHING
+RETURNING *;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: GetWebhookEventByEventID :one
+SELECT * FROM webhook_events
+WHERE source = $1 AND event_id = $2;
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status')
+ORDER BY received_at DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ClaimWebhookEvent :one
+-- Returns no rows if the event is being handled, or was handled already.
+-- A claim made before stale_before is taken to have been abandoned.
+UPDATE webhook_events
+SET status = 'processing', claimed_at = NOW()
+WHERE id = sqlc.arg('id')
+AND (
+    status IN ('received', 'failed')
+    OR (status = 'processing' AND (claimed_at IS NULL OR claimed_at < sqlc.arg('stale_before')))
+)
+RETURNING *;
+
+-- name: ClaimWebhookEventForReplay :one
+-- Returns no rows if the event is being handled.
+UPDATE webhook_events
+SET status = 'processing', claimed_at = NOW()
+WHERE id = sqlc.arg('id')
+AND (
+    status <> 'processing'
+    OR claimed_at IS NULL
+    OR claimed_at < sqlc.arg('stale_before')
+)
+RETURNING *;
+
+-- name: FinishWebhookEvent :one
+UPDATE webhook_events
+SET status = $2,
+    error = $3,
+    attempts = attempts + 1,
+    processed_at = NOW()
+WHERE id = $1
+RETURNING *;
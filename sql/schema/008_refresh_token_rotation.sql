@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE refresh_tokens
+ADD COLUMN parent_token TEXT
+REFERENCES refresh_tokens(token)
+ON DELETE SET NULL;
+
+ALTER TABLE refresh_tokens
+ADD COLUMN rotated_at TIMESTAMP;
+
+CREATE INDEX idx_refresh_tokens_parent_token ON refresh_tokens (parent_token);
+
+-- +goose Down
+DROP INDEX idx_refresh_tokens_parent_token;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN rotated_at;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN parent_token;
@@ -0,0 +1,28 @@
+-- +goose Up
+ALTER TABLE refresh_tokens
+ADD COLUMN session_id UUID NOT NULL
+DEFAULT gen_random_uuid();
+
+ALTER TABLE refresh_tokens
+ADD COLUMN user_agent TEXT NOT NULL
+DEFAULT '';
+
+ALTER TABLE refresh_tokens
+ADD COLUMN ip_address TEXT NOT NULL
+DEFAULT '';
+
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+CREATE INDEX idx_refresh_tokens_session_id ON refresh_tokens (session_id);
+
+-- +goose Down
+DROP INDEX idx_refresh_tokens_session_id;
+DROP INDEX idx_refresh_tokens_user_id;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN ip_address;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN user_agent;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN session_id;
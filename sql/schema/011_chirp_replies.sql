@@ -0,0 +1,26 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN in_reply_to_id UUID
+REFERENCES chirps(id)
+ON DELETE SET NULL;
+
+ALTER TABLE chirps
+ADD COLUMN reply_count INTEGER NOT NULL
+DEFAULT 0;
+
+ALTER TABLE chirps
+ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_chirps_in_reply_to_id ON chirps (in_reply_to_id);
+
+-- +goose Down
+DROP INDEX idx_chirps_in_reply_to_id;
+
+ALTER TABLE chirps
+DROP COLUMN deleted_at;
+
+ALTER TABLE chirps
+DROP COLUMN reply_count;
+
+ALTER TABLE chirps
+DROP COLUMN in_reply_to_id;
@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN rechirp_of_id UUID
+REFERENCES chirps(id)
+ON DELETE CASCADE;
+
+-- No foreign key: a quote keeps pointing at its chirp after that chirp is
+-- deleted so it can be shown as unavailable.
+ALTER TABLE chirps
+ADD COLUMN quoted_chirp_id UUID;
+
+CREATE UNIQUE INDEX idx_chirps_user_id_rechirp_of_id ON chirps (user_id, rechirp_of_id)
+WHERE rechirp_of_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX idx_chirps_user_id_rechirp_of_id;
+
+ALTER TABLE chirps
+DROP COLUMN quoted_chirp_id;
+
+ALTER TABLE chirps
+DROP COLUMN rechirp_of_id;
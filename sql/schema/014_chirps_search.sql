@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN search_vector tsvector
+GENERATED ALWAYS AS (to_tsvector('english', body)) STORED;
+
+CREATE INDEX idx_chirps_search_vector ON chirps USING GIN (search_vector);
+
+CREATE INDEX idx_users_email_pattern ON users (lower(email) text_pattern_ops);
+
+-- +goose Down
+DROP INDEX idx_users_email_pattern;
+
+DROP INDEX idx_chirps_search_vector;
+
+ALTER TABLE chirps
+DROP COLUMN search_vector;
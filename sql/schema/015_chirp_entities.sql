@@ -0,0 +1,39 @@
+-- +goose Up
+CREATE TABLE chirp_hashtags (
+    chirp_id UUID NOT NULL,
+    tag TEXT NOT NULL,
+    start_offset INTEGER NOT NULL,
+    end_offset INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, start_offset),
+    CONSTRAINT fk_chirp_id
+    FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX idx_chirp_hashtags_tag ON chirp_hashtags (tag, created_at);
+CREATE INDEX idx_chirp_hashtags_created_at ON chirp_hashtags (created_at);
+
+CREATE TABLE chirp_mentions (
+    chirp_id UUID NOT NULL,
+    handle TEXT NOT NULL,
+    user_id UUID,
+    start_offset INTEGER NOT NULL,
+    end_offset INTEGER NOT NULL,
+    PRIMARY KEY (chirp_id, start_offset),
+    CONSTRAINT fk_chirp_id
+    FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE SET NULL
+);
+
+CREATE INDEX idx_chirp_mentions_handle ON chirp_mentions (handle);
+
+-- +goose Down
+DROP TABLE chirp_mentions;
+DROP TABLE chirp_hashtags;
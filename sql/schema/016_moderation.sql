@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE TABLE moderation_words (
+    word TEXT PRIMARY KEY,
+    action TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    CONSTRAINT valid_action
+    CHECK (action IN ('censor', 'flag', 'reject'))
+);
+
+INSERT INTO moderation_words(word, action, created_at, updated_at)
+VALUES
+    ('kerfuffle', 'censor', NOW(), NOW()),
+    ('sharbert', 'censor', NOW(), NOW()),
+    ('fornax', 'censor', NOW(), NOW());
+
+CREATE TABLE chirp_moderation (
+    chirp_id UUID PRIMARY KEY,
+    action TEXT NOT NULL,
+    verdicts JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    reviewed_at TIMESTAMP,
+    CONSTRAINT fk_chirp_id
+    FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX idx_chirp_moderation_flagged ON chirp_moderation (created_at)
+WHERE action = 'flag' AND reviewed_at IS NULL;
+
+-- +goose Down
+DROP TABLE chirp_moderation;
+DROP TABLE moderation_words;
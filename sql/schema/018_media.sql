@@ -0,0 +1,37 @@
+-- +goose Up
+CREATE TABLE media (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    storage_key TEXT NOT NULL,
+    thumbnail_key TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size_bytes INTEGER NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE TABLE chirp_media (
+    chirp_id UUID NOT NULL,
+    media_id UUID NOT NULL UNIQUE,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (chirp_id, position),
+    CONSTRAINT fk_chirp_id
+    FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_media_id
+    FOREIGN KEY (media_id)
+    REFERENCES media(id)
+    ON DELETE CASCADE,
+    CONSTRAINT max_attachments
+    CHECK (position BETWEEN 0 AND 3)
+);
+
+-- +goose Down
+DROP TABLE chirp_media;
+DROP TABLE media;
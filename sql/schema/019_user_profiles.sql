@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN handle TEXT,
+ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+ADD COLUMN bio TEXT NOT NULL DEFAULT '',
+ADD COLUMN location TEXT NOT NULL DEFAULT '',
+ADD COLUMN avatar_media_id UUID,
+ADD CONSTRAINT fk_avatar_media_id
+FOREIGN KEY (avatar_media_id)
+REFERENCES media(id)
+ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX idx_users_handle ON users (lower(handle));
+
+-- +goose Down
+DROP INDEX idx_users_handle;
+
+ALTER TABLE users
+DROP COLUMN avatar_media_id,
+DROP COLUMN location,
+DROP COLUMN bio,
+DROP COLUMN display_name,
+DROP COLUMN handle;
@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE TABLE data_exports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    status TEXT NOT NULL,
+    storage_key TEXT,
+    expires_at TIMESTAMP,
+    CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX idx_data_exports_user_id ON data_exports (user_id, created_at);
+
+-- +goose Down
+DROP TABLE data_exports;
+
+ALTER TABLE users
+DROP COLUMN deleted_at;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Accounts at external OpenID Connect providers, linked to the Chirpy users
+-- they sign in as. A provider's subject never changes, unlike the email.
+CREATE TABLE user_identities (
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    email TEXT NOT NULL,
+    PRIMARY KEY (issuer, subject),
+    CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_identities_user_id ON user_identities (user_id);
+
+-- +goose Down
+DROP TABLE user_identities;
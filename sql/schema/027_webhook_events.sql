@@ -0,0 +1,21 @@
+-- +goose Up
+-- Every webhook delivery we've accepted, so retries of the same event are
+-- only acted on once and failed ones can be replayed.
+CREATE TABLE webhook_events (
+    id UUID PRIMARY KEY,
+    received_at TIMESTAMP NOT NULL,
+    source TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL,
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    processed_at TIMESTAMP,
+    UNIQUE (source, event_id)
+);
+
+CREATE INDEX idx_webhook_events_status ON webhook_events (status, received_at);
+
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,41 @@
+-- +goose Up
+-- A user's Chirpy Red subscription, kept in step with Polka's webhooks.
+-- Whether someone is a member is worked out from it rather than stored.
+CREATE TABLE subscriptions (
+    user_id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL,
+    current_period_end TIMESTAMP NOT NULL,
+    canceled_at TIMESTAMP,
+    grace_period_end TIMESTAMP,
+    CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX idx_subscriptions_status ON subscriptions (status, current_period_end);
+
+-- Existing members never had a period, so they get a fresh one.
+INSERT INTO subscriptions (user_id, created_at, updated_at, plan, status, current_period_end)
+SELECT id, NOW(), NOW(), 'red', 'active', NOW() + INTERVAL '30 days'
+FROM users
+WHERE is_chirpy_red;
+
+ALTER TABLE users
+DROP COLUMN is_chirpy_red;
+
+-- +goose Down
+ALTER TABLE users
+ADD COLUMN is_chirpy_red BOOLEAN NOT NULL
+DEFAULT FALSE;
+
+UPDATE users
+SET is_chirpy_red = TRUE
+FROM subscriptions
+WHERE subscriptions.user_id = users.id
+AND subscriptions.status <> 'expired';
+
+DROP TABLE subscriptions;
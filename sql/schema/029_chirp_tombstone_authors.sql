@@ -0,0 +1,12 @@
+-- +goose Up
+-- Purging an account keeps its chirps that others replied to as tombstones,
+-- so their threads still hang together. Those no longer have an author.
+ALTER TABLE chirps
+ALTER COLUMN user_id DROP NOT NULL;
+
+-- +goose Down
+DELETE FROM chirps
+WHERE user_id IS NULL;
+
+ALTER TABLE chirps
+ALTER COLUMN user_id SET NOT NULL;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- At most one export per user is built at a time.
+CREATE UNIQUE INDEX idx_data_exports_user_id_pending ON data_exports (user_id)
+WHERE status = 'pending';
+
+-- +goose Down
+DROP INDEX idx_data_exports_user_id_pending;
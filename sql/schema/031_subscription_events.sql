@@ -0,0 +1,11 @@
+-- +goose Up
+-- The last webhook event applied to each subscription, so a duplicate
+-- delivery isn't applied twice and an older event can't undo a newer one.
+ALTER TABLE subscriptions
+ADD COLUMN last_event_id UUID,
+ADD COLUMN last_event_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE subscriptions
+DROP COLUMN last_event_id,
+DROP COLUMN last_event_at;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- When an event was last claimed for processing, so a claim whose holder
+-- died can be taken over once it's old enough.
+ALTER TABLE webhook_events
+ADD COLUMN claimed_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE webhook_events
+DROP COLUMN claimed_at;
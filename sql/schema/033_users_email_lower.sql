@@ -0,0 +1,6 @@
+-- +goose Up
+-- Emails from identity providers are matched to users ignoring case.
+CREATE INDEX idx_users_email_lower ON users (lower(email));
+
+-- +goose Down
+DROP INDEX idx_users_email_lower;
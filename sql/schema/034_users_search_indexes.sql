@@ -0,0 +1,15 @@
+-- +goose Up
+-- User search matches handle and display name prefixes. Emails aren't
+-- searchable, so other users' addresses can't be found by prefix.
+DROP INDEX idx_users_email_pattern;
+
+CREATE INDEX idx_users_handle_pattern ON users (lower(handle) text_pattern_ops);
+
+CREATE INDEX idx_users_display_name_pattern ON users (lower(display_name) text_pattern_ops);
+
+-- +goose Down
+DROP INDEX idx_users_display_name_pattern;
+
+DROP INDEX idx_users_handle_pattern;
+
+CREATE INDEX idx_users_email_pattern ON users (lower(email) text_pattern_ops);
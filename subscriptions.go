@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/database"
+)
+
+const (
+	defaultSubscriptionPlan = "red"
+	// subscriptionPeriod is how long a payment lasts when Polka doesn't
+	// say.
+	subscriptionPeriod = 30 * 24 * time.Hour
+	// subscriptionGracePeriod is how long members keep Chirpy Red after a
+	// failed payment, while Polka retries it.
+	subscriptionGracePeriod = 7 * 24 * time.Hour
+
+	subscriptionActive   = "active"
+	subscriptionPastDue  = "past_due"
+	subscriptionCanceled = "canceled"
+	subscriptionExpired  = "expired"
+	subscriptionNone     = "none"
+)
+
+type Subscription struct {
+	Plan             string     `json:"plan,omitempty"`
+	Status           string     `json:"status"`
+	CurrentPeriodEnd *time.Time `json:"current_period_end,omitempty"`
+	CanceledAt       *time.Time `json:"canceled_at,omitempty"`
+	GracePeriodEnd   *time.Time `json:"grace_period_end,omitempty"`
+	IsChirpyRed      bool       `json:"is_chirpy_red"`
+}
+
+func subscriptionFromDB(s database.Subscription, now time.Time) Subscription {
+	sub := Subscription{
+		Plan:             s.Plan,
+		Status:           s.Status,
+		CurrentPeriodEnd: &s.CurrentPeriodEnd,
+		IsChirpyRed:      subscriptionIsActive(s, now),
+	}
+	if s.CanceledAt.Valid {
+		sub.CanceledAt = &s.CanceledAt.Time
+	}
+	if s.GracePeriodEnd.Valid {
+		sub.GracePeriodEnd = &s.GracePeriodEnd.Time
+	}
+	return sub
+}
+
+// subscriptionIsActive reports whether s gives Chirpy Red at now. Canceled
+// subscriptions last until the end of the period that was paid for, and
+// past-due ones until their grace period runs out. It doesn't wait for
+// the expiry job to catch up.
+func subscriptionIsActive(s database.Subscription, now time.Time) bool {
+	switch s.Status {
+	case subscriptionActive, subscriptionCanceled:
+		return now.Before(s.CurrentPeriodEnd)
+	case subscriptionPastDue:
+		return s.GracePeriodEnd.Valid && now.Before(s.GracePeriodEnd.Time)
+	}
+	return false
+}
+
+// isChirpyRed reports whether the user has an active subscription.
+func (cfg *apiConfig) isChirpyRed(ctx context.Context, userID uuid.UUID) (bool, error) {
+	sub, err := cfg.db.GetSubscription(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return subscriptionIsActive(sub, time.Now().UTC()), nil
+}
+
+func (cfg *apiConfig) handlerSubscription(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r.Context()).UserID
+
+	sub, err := cfg.db.GetSubscription(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithJSON(w, http.StatusOK, Subscription{Status: subscriptionNone})
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve subscription", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, subscriptionFromDB(sub, time.Now().UTC()))
+}
+
+// polkaSubscriptionEvent applies a membership event from Polka to the
+// user's subscription. Events are ordered by when Chirpy first received
+// them. One received before the last event applied is stale, whether Polka
+// delivered it late or an admin replayed it, and is ignored.
+func (cfg *apiConfig) polkaSubscriptionEvent(ctx context.Context, stored database.WebhookEvent, event polkaEvent) error {
+	userID := event.Data.UserID
+	_, err := cfg.db.GetUserByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &webhookError{status: http.StatusNotFound, msg: "user can not be found"}
+	}
+	if err != nil {
+		return err
+	}
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// Events for the same user are applied one at a time.
+	var current *database.Subscription
+	sub, err := qtx.GetSubscriptionForUpdate(ctx, userID)
+	switch {
+	case err == nil:
+		current = &sub
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+	if current != nil && current.LastEventID.Valid {
+		// Already applied, so a renewal doesn't add a second period.
+		if current.LastEventID.UUID == stored.ID {
+			return nil
+		}
+		if stored.ReceivedAt.Before(current.LastEventAt.Time) {
+			return errWebhookIgnored
+		}
+	}
+
+	lastEventID := uuid.NullUUID{UUID: stored.ID, Valid: true}
+	lastEventAt := sql.NullTime{Time: stored.ReceivedAt, Valid: true}
+	now := time.Now().UTC()
+	switch event.Event {
+	case polkaUserUpgraded, polkaUserRenewed:
+		periodEnd, plan := nextSubscriptionPeriod(event, current, now)
+		_, err = qtx.ActivateSubscription(ctx, database.ActivateSubscriptionParams{
+			UserID:           userID,
+			Plan:             plan,
+			CurrentPeriodEnd: periodEnd,
+			LastEventID:      lastEventID,
+			LastEventAt:      lastEventAt,
+		})
+	case polkaUserDowngraded:
+		_, err = qtx.CancelSubscription(ctx, database.CancelSubscriptionParams{
+			UserID:      userID,
+			LastEventID: lastEventID,
+			LastEventAt: lastEventAt,
+		})
+	case polkaPaymentFailed:
+		if current == nil {
+			err = sql.ErrNoRows
+			break
+		}
+		// A retry that fails again doesn't push the grace period back.
+		graceEnd := latest(now, current.CurrentPeriodEnd).Add(subscriptionGracePeriod)
+		if current.Status == subscriptionPastDue && current.GracePeriodEnd.Valid {
+			graceEnd = current.GracePeriodEnd.Time
+		}
+		_, err = qtx.MarkSubscriptionPastDue(ctx, database.MarkSubscriptionPastDueParams{
+			UserID:         userID,
+			GracePeriodEnd: sql.NullTime{Time: graceEnd, Valid: true},
+			LastEventID:    lastEventID,
+			LastEventAt:    lastEventAt,
+		})
+	default:
+		return errWebhookIgnored
+	}
+	// Canceling or missing a payment on a subscription that's already over,
+	// or that never existed, changes nothing. Neither does an upgrade that
+	// lost a race with a newer event for a user who had no subscription.
+	if errors.Is(err, sql.ErrNoRows) {
+		return errWebhookIgnored
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// nextSubscriptionPeriod works out when a new or renewed subscription's
+// period ends, given the current subscription if there is one. Polka may
+// say; otherwise a renewal adds a period to the current one, so renewing
+// early doesn't lose any time.
+func nextSubscriptionPeriod(event polkaEvent, current *database.Subscription, now time.Time) (time.Time, string) {
+	plan := event.Data.Plan
+	if event.Data.CurrentPeriodEnd != nil {
+		if plan == "" {
+			plan = defaultSubscriptionPlan
+		}
+		return event.Data.CurrentPeriodEnd.UTC(), plan
+	}
+
+	start := now
+	if current != nil {
+		if event.Event == polkaUserRenewed && current.Status != subscriptionExpired {
+			start = latest(now, current.CurrentPeriodEnd)
+		}
+		if plan == "" {
+			plan = current.Plan
+		}
+	}
+	if plan == "" {
+		plan = defaultSubscriptionPlan
+	}
+	return start.Add(subscriptionPeriod), plan
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// expireSubscriptions marks subscriptions that have lapsed as expired.
+func (cfg *apiConfig) expireSubscriptions(ctx context.Context) {
+	expired, err := cfg.db.ExpireSubscriptions(ctx, time.Now().UTC())
+	if err != nil {
+		log.Printf("Error expiring subscriptions: %s", err)
+		return
+	}
+	if expired > 0 {
+		log.Printf("Expired %d subscriptions", expired)
+	}
+}
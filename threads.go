@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/database"
+)
+
+// maxThreadReplies caps how much of a conversation a single thread request
+// will load.
+const maxThreadReplies = 500
+
+type ChirpNode struct {
+	Chirp
+	Replies []ChirpNode `json:"replies"`
+}
+
+func (cfg *apiConfig) handlerChirpThread(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Ancestors []Chirp   `json:"ancestors"`
+		Chirp     ChirpNode `json:"chirp"`
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	dbChirp, err := cfg.db.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Not Found", err)
+		return
+	}
+
+	dbAncestors, err := cfg.db.ListChirpAncestors(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve thread", err)
+		return
+	}
+	ancestors := chirpsFromDB(dbAncestors)
+
+	dbReplies, err := cfg.db.ListChirpReplies(r.Context(), database.ListChirpRepliesParams{
+		ChirpID:   chirpID,
+		PageLimit: maxThreadReplies,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve thread", err)
+		return
+	}
+
+	root := buildReplyTree(dbChirp, dbReplies)
+	refs := chirpRefs(ancestors)
+	var collect func(node *ChirpNode)
+	collect = func(node *ChirpNode) {
+		refs = append(refs, &node.Chirp)
+		for i := range node.Replies {
+			collect(&node.Replies[i])
+		}
+	}
+	collect(&root)
+	if err := cfg.decorateChirps(r, refs); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve thread", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		Ancestors: ancestors,
+		Chirp:     root,
+	})
+}
+
+// buildReplyTree nests replies under their parents. Replies come back oldest
+// first, so each level of the tree stays in chronological order.
+func buildReplyTree(root database.Chirp, replies []database.Chirp) ChirpNode {
+	children := map[uuid.UUID][]database.Chirp{}
+	for _, reply := range replies {
+		parentID := reply.InReplyToID.UUID
+		children[parentID] = append(children[parentID], reply)
+	}
+
+	var build func(c database.Chirp) ChirpNode
+	build = func(c database.Chirp) ChirpNode {
+		node := ChirpNode{Chirp: chirpFromDB(c), Replies: []ChirpNode{}}
+		for _, child := range children[c.ID] {
+			node.Replies = append(node.Replies, build(child))
+		}
+		return node
+	}
+	return build(root)
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/mrcordova/chirpy/internal/auth"
+	"github.com/mrcordova/chirpy/internal/database"
+	"github.com/mrcordova/chirpy/internal/qr"
+)
+
+const (
+	totpIssuer             = "Chirpy"
+	twoFactorChallengeTime = 5 * time.Minute
+	recoveryCodeCount      = 10
+	qrCodeScale            = 6
+)
+
+// respondWithTwoFactorChallenge answers a login with the right password
+// for an account with 2FA on. The client trades the challenge token and a
+// code for the usual tokens at /api/login/2fa.
+func (cfg *apiConfig) respondWithTwoFactorChallenge(w http.ResponseWriter, user database.User) {
+	type response struct {
+		TwoFactorRequired bool   `json:"two_factor_required"`
+		ChallengeToken    string `json:"challenge_token"`
+	}
+
+	challenge, err := auth.MakeChallengeJWT(user.ID, cfg.jwtKeys, twoFactorChallengeTime)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create challenge token", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, response{
+		TwoFactorRequired: true,
+		ChallengeToken:    challenge,
+	})
+}
+
+// checkSecondFactor reports whether code is a current authenticator code,
+// or recoveryCode an unused recovery code, for user. Either is used up by a
+// successful check.
+func (cfg *apiConfig) checkSecondFactor(ctx context.Context, user database.User, code, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		rows, err := cfg.db.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+			UserID:   user.ID,
+			CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(recoveryCode)),
+		})
+		return rows == 1, err
+	}
+
+	step, ok := auth.ValidateTOTP(user.TotpSecret.String, code, time.Now().UTC())
+	if !ok {
+		return false, nil
+	}
+	// Only steps after the last one used are accepted, so a code someone
+	// watched being typed can't be replayed.
+	rows, err := cfg.db.UseTOTPStep(ctx, database.UseTOTPStepParams{
+		ID:           user.ID,
+		TotpLastStep: step,
+	})
+	return rows == 1, err
+}
+
+func (cfg *apiConfig) handlerLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	userID, err := auth.ValidateChallengeJWT(params.ChallengeToken, cfg.jwtKeys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge token", err)
+		return
+	}
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find user", err)
+		return
+	}
+	if !user.TotpEnabledAt.Valid {
+		respondWithError(w, http.StatusUnauthorized, "Two-factor authentication isn't enabled", nil)
+		return
+	}
+
+	// Wrong codes count as failed logins, so the challenge token can't be
+	// used to guess codes any faster than passwords.
+	if !cfg.checkLoginLockout(w, r, user.Email) {
+		return
+	}
+	ok, err := cfg.checkSecondFactor(r.Context(), user, params.Code, params.RecoveryCode)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+		return
+	}
+	if !ok {
+		if err := cfg.recordLoginFailure(r.Context(), user.Email, clientIP(r)); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+			return
+		}
+		respondWithError(w, http.StatusUnauthorized, "Invalid two-factor code", nil)
+		return
+	}
+
+	cfg.completeLogin(w, r, user)
+}
+
+// handlerTwoFactorEnroll starts setting up an authenticator app. 2FA isn't
+// on until the user proves the app works at /api/2fa/confirm.
+func (cfg *apiConfig) handlerTwoFactorEnroll(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Secret     string  `json:"secret"`
+		OTPAuthURI string  `json:"otpauth_uri"`
+		QRCode     *string `json:"qr_code"`
+	}
+
+	userID := principalFrom(r.Context()).UserID
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find user", err)
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+		return
+	}
+
+	secret, err := auth.MakeTOTPSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create secret", err)
+		return
+	}
+	_, err = cfg.db.SetTOTPSecret(r.Context(), database.SetTOTPSecretParams{
+		ID:         user.ID,
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save secret", err)
+		return
+	}
+
+	uri := auth.TOTPURI(totpIssuer, user.Email, secret)
+	resp := response{
+		Secret:     secret,
+		OTPAuthURI: uri,
+	}
+	// A very long email can make the URI too big for a QR code. The secret
+	// can still be typed in by hand.
+	code, err := qr.Encode([]byte(uri))
+	if err != nil && !errors.Is(err, qr.ErrTooLong) {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create QR code", err)
+		return
+	}
+	if err == nil {
+		png, err := code.PNG(qrCodeScale)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create QR code", err)
+			return
+		}
+		dataURI := "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+		resp.QRCode = &dataURI
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// handlerTwoFactorConfirm turns 2FA on once the user sends a code from
+// their newly set up app, and hands out recovery codes. This is the only
+// time the recovery codes are shown.
+func (cfg *apiConfig) handlerTwoFactorConfirm(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+	type response struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	userID := principalFrom(r.Context()).UserID
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find user", err)
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+		return
+	}
+	if !user.TotpSecret.Valid {
+		respondWithError(w, http.StatusBadRequest, "Start two-factor enrollment first", nil)
+		return
+	}
+	// Wrong codes count as failed logins, like everywhere else a code is
+	// checked.
+	if !cfg.checkLoginLockout(w, r, user.Email) {
+		return
+	}
+	step, ok := auth.ValidateTOTP(user.TotpSecret.String, params.Code, time.Now().UTC())
+	if !ok {
+		if err := cfg.recordLoginFailure(r.Context(), user.Email, clientIP(r)); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't enable two-factor authentication", err)
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid two-factor code", nil)
+		return
+	}
+
+	codes, err := auth.MakeRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create recovery codes", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't enable two-factor authentication", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	_, err = qtx.EnableTOTP(r.Context(), database.EnableTOTPParams{
+		ID:           user.ID,
+		TotpLastStep: step,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't enable two-factor authentication", err)
+		return
+	}
+	if err := qtx.DeleteRecoveryCodes(r.Context(), user.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't enable two-factor authentication", err)
+		return
+	}
+	for _, code := range codes {
+		err := qtx.CreateRecoveryCode(r.Context(), database.CreateRecoveryCodeParams{
+			CodeHash: auth.HashToken(code),
+			UserID:   user.ID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't enable two-factor authentication", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't enable two-factor authentication", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{RecoveryCodes: codes})
+}
+
+// handlerTwoFactorDisable turns 2FA off. Like other credential changes it
+// needs the current password, and a code as well so a stolen password
+// alone can't remove the second factor.
+func (cfg *apiConfig) handlerTwoFactorDisable(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		CurrentPassword string `json:"current_password"`
+		Code            string `json:"code"`
+		RecoveryCode    string `json:"recovery_code"`
+	}
+
+	userID := principalFrom(r.Context()).UserID
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find user", err)
+		return
+	}
+	if !cfg.checkCurrentPassword(w, r, user, params.CurrentPassword) {
+		return
+	}
+
+	if user.TotpEnabledAt.Valid {
+		ok, err := cfg.checkSecondFactor(r.Context(), user, params.Code, params.RecoveryCode)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+			return
+		}
+		if !ok {
+			// Counted like a failed login, so the password and a session
+			// can't be used to guess codes without limit.
+			if err := cfg.recordLoginFailure(r.Context(), user.Email, clientIP(r)); err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+				return
+			}
+			respondWithError(w, http.StatusUnauthorized, "Invalid two-factor code", nil)
+			return
+		}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	if err := qtx.DisableTOTP(r.Context(), user.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+		return
+	}
+	if err := qtx.DeleteRecoveryCodes(r.Context(), user.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
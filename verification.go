@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/auth"
+	"github.com/mrcordova/chirpy/internal/database"
+	"github.com/mrcordova/chirpy/internal/mailer"
+)
+
+const (
+	verifyEmailTokenDuration   = 24 * time.Hour
+	passwordResetTokenDuration = time.Hour
+	// mailSendTimeout bounds sending one email, so a stuck mail server
+	// doesn't hold up the request waiting on it.
+	mailSendTimeout = 30 * time.Second
+	// mailLimitReset is how long after the last one the count of emails
+	// asked for starts over.
+	mailLimitReset = time.Hour
+
+	tokenPurposeVerifyEmail   = "verify_email"
+	tokenPurposePasswordReset = "password_reset"
+)
+
+// Password reset and verification emails are limited per address, so an
+// inbox can't be flooded, and per client IP.
+var (
+	mailAccountLimit = auth.LockoutPolicy{Threshold: 3, Base: 15 * time.Minute, Max: 24 * time.Hour}
+	mailIPLimit      = auth.LockoutPolicy{Threshold: 10, Base: 15 * time.Minute, Max: 24 * time.Hour}
+)
+
+func passwordResetLockouts(email, ip string) []lockout {
+	return []lockout{
+		{"password_reset:" + accountLoginKey(email), mailAccountLimit},
+		{"password_reset:" + ipLoginKey(ip), mailIPLimit},
+	}
+}
+
+func verifyEmailLockouts(email, ip string) []lockout {
+	return []lockout{
+		{tokenPurposeVerifyEmail + ":" + accountLoginKey(email), mailAccountLimit},
+		{tokenPurposeVerifyEmail + ":" + ipLoginKey(ip), mailIPLimit},
+	}
+}
+
+// Actions that UNVERIFIED_RESTRICTIONS can keep unverified accounts from
+// taking.
+const (
+	actionChirp  = "chirp"
+	actionMedia  = "media"
+	actionFollow = "follow"
+	actionLike   = "like"
+)
+
+var restrictableActions = map[string]struct{}{
+	actionChirp:  {},
+	actionMedia:  {},
+	actionFollow: {},
+	actionLike:   {},
+}
+
+// parseRestrictions reads a comma-separated list of actions.
+func parseRestrictions(s string) (map[string]bool, error) {
+	restricted := map[string]bool{}
+	for _, action := range strings.Split(s, ",") {
+		action = strings.TrimSpace(action)
+		if action == "" {
+			continue
+		}
+		if _, ok := restrictableActions[action]; !ok {
+			return nil, fmt.Errorf("unknown action %q", action)
+		}
+		restricted[action] = true
+	}
+	return restricted, nil
+}
+
+// requireVerified writes a 403 and returns false if action is restricted
+// for unverified accounts and the user hasn't verified their email.
+func (cfg *apiConfig) requireVerified(w http.ResponseWriter, r *http.Request, userID uuid.UUID, action string) bool {
+	if !cfg.unverifiedRestrictions[action] {
+		return true
+	}
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find user", err)
+		return false
+	}
+	if !user.EmailVerifiedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "Verify your email address first", nil)
+		return false
+	}
+	return true
+}
+
+// issueUserToken makes a new single-use token, replacing any the user
+// already has for the same purpose. Only its hash is stored.
+func issueUserToken(ctx context.Context, db *database.Queries, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	err = db.InvalidateUserTokens(ctx, database.InvalidateUserTokensParams{
+		UserID:  userID,
+		Purpose: purpose,
+	})
+	if err != nil {
+		return "", err
+	}
+	_, err = db.CreateUserToken(ctx, database.CreateUserTokenParams{
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().UTC().Add(ttl),
+		UserID:    userID,
+		Purpose:   purpose,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (cfg *apiConfig) tokenLink(path, token string) string {
+	return cfg.appBaseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func (cfg *apiConfig) sendVerificationEmail(ctx context.Context, user database.User) error {
+	token, err := issueUserToken(ctx, cfg.db, user.ID, tokenPurposeVerifyEmail, verifyEmailTokenDuration)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, mailSendTimeout)
+	defer cancel()
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your Chirpy email address",
+		Body: fmt.Sprintf(
+			"Confirm this is your email address by opening the link below.\n\n%s\n\nThe link expires in %s.\n",
+			cfg.tokenLink("/app/verify-email", token),
+			verifyEmailTokenDuration,
+		),
+	})
+}
+
+func (cfg *apiConfig) handlerVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token string `json:"token"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	used, err := cfg.db.UseUserToken(r.Context(), database.UseUserTokenParams{
+		TokenHash: auth.HashToken(params.Token),
+		Purpose:   tokenPurposeVerifyEmail,
+		Now:       time.Now().UTC(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't verify email", err)
+		return
+	}
+
+	user, err := cfg.db.MarkEmailVerified(r.Context(), used.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't verify email", err)
+		return
+	}
+
+	verified, err := cfg.userFromDB(r.Context(), user)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve user", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, verified)
+}
+
+func (cfg *apiConfig) handlerVerifyEmailResend(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r.Context()).UserID
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find user", err)
+		return
+	}
+	if user.EmailVerifiedAt.Valid {
+		respondWithError(w, http.StatusConflict, "Email is already verified", nil)
+		return
+	}
+
+	// Otherwise someone could set their email to another person's address
+	// and flood it.
+	lockouts := verifyEmailLockouts(user.Email, clientIP(r))
+	if !cfg.checkLockout(w, r, lockouts, "Too many verification emails, try again later") {
+		return
+	}
+	if err := cfg.recordFailures(r.Context(), lockouts, mailLimitReset); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send verification email", err)
+		return
+	}
+
+	if err := cfg.sendVerificationEmail(r.Context(), user); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send verification email", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handlerPasswordResetRequest responds the same way whether or not the email
+// belongs to an account, so it can't be used to find out who has one. The
+// account is looked up and mailed in the background, so the response takes
+// as long either way.
+func (cfg *apiConfig) handlerPasswordResetRequest(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Every request counts, whether or not the email has an account.
+	lockouts := passwordResetLockouts(params.Email, clientIP(r))
+	if !cfg.checkLockout(w, r, lockouts, "Too many password reset requests, try again later") {
+		return
+	}
+	if err := cfg.recordFailures(r.Context(), lockouts, mailLimitReset); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't request password reset", err)
+		return
+	}
+
+	go cfg.requestPasswordReset(params.Email)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// requestPasswordReset mails a reset link to email if it belongs to an
+// account.
+func (cfg *apiConfig) requestPasswordReset(email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+	defer cancel()
+
+	user, err := cfg.db.GetUser(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err == nil {
+		err = cfg.sendPasswordResetEmail(ctx, user)
+	}
+	if err != nil {
+		log.Printf("Error sending password reset email: %s", err)
+	}
+}
+
+func (cfg *apiConfig) sendPasswordResetEmail(ctx context.Context, user database.User) error {
+	token, err := issueUserToken(ctx, cfg.db, user.ID, tokenPurposePasswordReset, passwordResetTokenDuration)
+	if err != nil {
+		return err
+	}
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password for your account. If it was you, open the link below to choose a new one.\n\n%s\n\nThe link expires in %s. If you didn't ask for this, you can ignore this email.\n",
+			cfg.tokenLink("/app/reset-password", token),
+			passwordResetTokenDuration,
+		),
+	})
+}
+
+// handlerPasswordResetConfirm sets a new password and logs out every
+// session and API key, since whoever had the old password may still be
+// signed in.
+func (cfg *apiConfig) handlerPasswordResetConfirm(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if params.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "Password can't be empty", nil)
+		return
+	}
+
+	hashPassword, err := auth.HashPassword(params.Password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reset password", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	used, err := qtx.UseUserToken(r.Context(), database.UseUserTokenParams{
+		TokenHash: auth.HashToken(params.Token),
+		Purpose:   tokenPurposePasswordReset,
+		Now:       time.Now().UTC(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reset password", err)
+		return
+	}
+
+	user, err := qtx.GetUserByID(r.Context(), used.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reset password", err)
+		return
+	}
+	_, err = qtx.UpdateUser(r.Context(), database.UpdateUserParams{
+		Email:          user.Email,
+		HashedPassword: hashPassword,
+		ID:             user.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reset password", err)
+		return
+	}
+	if err := qtx.RevokeUserSessions(r.Context(), user.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reset password", err)
+		return
+	}
+	if err := qtx.RevokeUserAPIKeys(r.Context(), user.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reset password", err)
+		return
+	}
+	// Getting the reset email shows the address belongs to them.
+	if _, err := qtx.MarkEmailVerified(r.Context(), user.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reset password", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reset password", err)
+		return
+	}
+
+	// Whoever reset the password owns the account, so any lockout from
+	// failed logins no longer serves a purpose.
+	if _, err := cfg.db.ClearLoginFailures(r.Context(), accountLoginKey(user.Email)); err != nil {
+		log.Printf("Error clearing login failures: %s", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrcordova/chirpy/internal/database"
+	"github.com/mrcordova/chirpy/internal/webhook"
+)
+
+const (
+	webhookSourcePolka   = "polka"
+	polkaSignatureHeader = "Polka-Signature"
+	maxWebhookBodyBytes  = 64 << 10
+	maxWebhookEvents     = 100
+	// webhookClaimTimeout is how long an event can be processing before
+	// another delivery or a replay may take it over.
+	webhookClaimTimeout = 5 * time.Minute
+
+	webhookStatusReceived   = "received"
+	webhookStatusProcessing = "processing"
+	webhookStatusProcessed  = "processed"
+	webhookStatusIgnored    = "ignored"
+	webhookStatusFailed     = "failed"
+
+	polkaUserUpgraded   = "user.upgraded"
+	polkaUserRenewed    = "user.renewed"
+	polkaUserDowngraded = "user.downgraded"
+	polkaPaymentFailed  = "user.payment_failed"
+)
+
+var webhookStatuses = map[string]struct{}{
+	webhookStatusReceived:   {},
+	webhookStatusProcessing: {},
+	webhookStatusProcessed:  {},
+	webhookStatusIgnored:    {},
+	webhookStatusFailed:     {},
+}
+
+// errWebhookIgnored is returned for events Chirpy doesn't act on.
+var errWebhookIgnored = errors.New("event type isn't handled")
+
+// webhookError is an event that can't be processed as sent, as opposed to
+// a failure processing it. The sender gets status rather than a 500, so it
+// doesn't keep retrying.
+type webhookError struct {
+	status int
+	msg    string
+}
+
+func (e *webhookError) Error() string {
+	return e.msg
+}
+
+type WebhookEvent struct {
+	ID          uuid.UUID       `json:"id"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	Source      string          `json:"source"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Error       *string         `json:"error"`
+	Attempts    int32           `json:"attempts"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+}
+
+func webhookEventFromDB(e database.WebhookEvent) WebhookEvent {
+	event := WebhookEvent{
+		ID:         e.ID,
+		ReceivedAt: e.ReceivedAt,
+		Source:     e.Source,
+		EventID:    e.EventID,
+		EventType:  e.EventType,
+		Payload:    e.Payload,
+		Status:     e.Status,
+		Error:      nullStringPtr(e.Error),
+		Attempts:   e.Attempts,
+	}
+	if e.ProcessedAt.Valid {
+		event.ProcessedAt = &e.ProcessedAt.Time
+	}
+	return event
+}
+
+// polkaEvent is the body of a Polka webhook. Plan and CurrentPeriodEnd
+// are only sent with upgrades and renewals, and may be left out.
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID           uuid.UUID  `json:"user_id"`
+		Plan             string     `json:"plan"`
+		CurrentPeriodEnd *time.Time `json:"current_period_end"`
+	} `json:"data"`
+}
+
+// handlerPolkaWebhook records a signed Polka event and acts on it. Polka
+// retries deliveries, so an event that was already handled is
+// acknowledged without being handled again, and one being handled by
+// another delivery is refused so that Polka tries again later.
+func (cfg *apiConfig) handlerPolkaWebhook(w http.ResponseWriter, r *http.Request) {
+	// Without a secret anyone could sign events.
+	if cfg.polkaApiKey == "" {
+		respondWithError(w, http.StatusForbidden, "Polka webhooks are disabled", nil)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Webhook body is too large", err)
+		return
+	}
+	err = webhook.Verify(cfg.polkaApiKey, r.Header.Get(polkaSignatureHeader), body, time.Now(), webhook.DefaultTolerance)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid webhook signature", err)
+		return
+	}
+
+	params := polkaEvent{}
+	if err := json.Unmarshal(body, &params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Unable to decode", err)
+		return
+	}
+	if params.ID == "" {
+		respondWithError(w, http.StatusBadRequest, "Event ID is required", nil)
+		return
+	}
+
+	event, err := cfg.db.CreateWebhookEvent(r.Context(), database.CreateWebhookEventParams{
+		Source:    webhookSourcePolka,
+		EventID:   params.ID,
+		EventType: params.Event,
+		Payload:   body,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		event, err = cfg.db.GetWebhookEventByEventID(r.Context(), database.GetWebhookEventByEventIDParams{
+			Source:  webhookSourcePolka,
+			EventID: params.ID,
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record webhook", err)
+		return
+	}
+
+	// Events that are new, or failed last time, are handled now, by
+	// whichever delivery claims them first.
+	claimed, err := cfg.db.ClaimWebhookEvent(r.Context(), database.ClaimWebhookEventParams{
+		ID:          event.ID,
+		StaleBefore: time.Now().UTC().Add(-webhookClaimTimeout),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		event, err = cfg.db.GetWebhookEvent(r.Context(), event.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't record webhook", err)
+			return
+		}
+		if event.Status == webhookStatusProcessing {
+			respondWithError(w, http.StatusConflict, "Event is already being processed", nil)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record webhook", err)
+		return
+	}
+
+	_, err = cfg.runWebhookEvent(r.Context(), claimed)
+	var whErr *webhookError
+	if errors.As(err, &whErr) {
+		respondWithError(w, whErr.status, whErr.msg, err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't process webhook", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runWebhookEvent handles an event the caller has claimed and records how
+// it went. The outcome is recorded even if ctx is canceled, so the event
+// isn't left claimed.
+func (cfg *apiConfig) runWebhookEvent(ctx context.Context, event database.WebhookEvent) (database.WebhookEvent, error) {
+	err := cfg.processPolkaEvent(ctx, event)
+
+	status := webhookStatusProcessed
+	var errMsg sql.NullString
+	switch {
+	case errors.Is(err, errWebhookIgnored):
+		status = webhookStatusIgnored
+		err = nil
+	case err != nil:
+		status = webhookStatusFailed
+		errMsg = sql.NullString{String: err.Error(), Valid: true}
+	}
+
+	finished, finishErr := cfg.db.FinishWebhookEvent(context.WithoutCancel(ctx), database.FinishWebhookEventParams{
+		ID:     event.ID,
+		Status: status,
+		Error:  errMsg,
+	})
+	if finishErr != nil {
+		return event, finishErr
+	}
+	return finished, err
+}
+
+// processPolkaEvent applies a stored Polka event. Replaying one is safe:
+// the subscription remembers the last event applied to it, and skips that
+// one and any received before it.
+func (cfg *apiConfig) processPolkaEvent(ctx context.Context, stored database.WebhookEvent) error {
+	params := polkaEvent{}
+	if err := json.Unmarshal(stored.Payload, &params); err != nil {
+		return &webhookError{status: http.StatusBadRequest, msg: "Unable to decode"}
+	}
+
+	switch params.Event {
+	case polkaUserUpgraded, polkaUserRenewed, polkaUserDowngraded, polkaPaymentFailed:
+		return cfg.polkaSubscriptionEvent(ctx, stored, params)
+	}
+	return errWebhookIgnored
+}
+
+// handlerWebhookEventsList shows recent webhook events, optionally only
+// those with ?status=.
+func (cfg *apiConfig) handlerWebhookEventsList(w http.ResponseWriter, r *http.Request) {
+	if !cfg.requireAdmin(w, r) {
+		return
+	}
+
+	status := sql.NullString{}
+	if s := r.URL.Query().Get("status"); s != "" {
+		if _, ok := webhookStatuses[s]; !ok {
+			respondWithError(w, http.StatusBadRequest, "Unknown status", nil)
+			return
+		}
+		status = sql.NullString{String: s, Valid: true}
+	}
+
+	dbEvents, err := cfg.db.ListWebhookEvents(r.Context(), database.ListWebhookEventsParams{
+		Status:    status,
+		PageLimit: maxWebhookEvents,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve webhook events", err)
+		return
+	}
+
+	events := make([]WebhookEvent, 0, len(dbEvents))
+	for _, dbEvent := range dbEvents {
+		events = append(events, webhookEventFromDB(dbEvent))
+	}
+	respondWithJSON(w, http.StatusOK, events)
+}
+
+// handlerWebhookEventReplay handles a stored event again, whatever happened
+// last time, and responds with how it went. An event that is being handled
+// right now can't be replayed until that finishes.
+func (cfg *apiConfig) handlerWebhookEventReplay(w http.ResponseWriter, r *http.Request) {
+	if !cfg.requireAdmin(w, r) {
+		return
+	}
+
+	eventID, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid event ID", err)
+		return
+	}
+	event, err := cfg.db.ClaimWebhookEventForReplay(r.Context(), database.ClaimWebhookEventForReplayParams{
+		ID:          eventID,
+		StaleBefore: time.Now().UTC().Add(-webhookClaimTimeout),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = cfg.db.GetWebhookEvent(r.Context(), eventID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Webhook event not found", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve webhook event", err)
+			return
+		}
+		respondWithError(w, http.StatusConflict, "Webhook event is being processed", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve webhook event", err)
+		return
+	}
+
+	event, err = cfg.runWebhookEvent(r.Context(), event)
+	var whErr *webhookError
+	if err != nil && !errors.As(err, &whErr) {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't replay webhook event", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, webhookEventFromDB(event))
+}